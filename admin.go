@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Admin endpoints
+// ─────────────────────────────────────────────────────────────
+
+// requireAdmin guards /admin/* with the -admin-token bearer token. With no
+// token configured the admin routes are open (local/dev use).
+func (s *Server) requireAdmin(c *fiber.Ctx) error {
+	if s.adminToken == "" {
+		return c.Next()
+	}
+	got := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(s.adminToken)) != 1 {
+		return fiber.NewError(fiber.StatusUnauthorized, "admin token required")
+	}
+	return c.Next()
+}
+
+type warmupReq struct {
+	Iters   int    `json:"iters"`   // default 10, max 1000
+	Pattern string `json:"pattern"` // zeros (default) | ones | random
+}
+
+// handleWarmup re-runs the warmup sequence on the primary model as it was
+// when the request arrived, so a reload partway through doesn't mix models.
+// Each forward takes gpuMu, so live traffic interleaves with it safely, and
+// a -global-rps token, so warmup can't push inference past the cap.
+func (s *Server) handleWarmup(c *fiber.Ctx) error {
+	var req warmupReq
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	if req.Iters == 0 {
+		req.Iters = 10
+	}
+	if req.Pattern == "" {
+		req.Pattern = "zeros"
+	}
+	if req.Iters < 0 || req.Iters > 1000 {
+		return fiber.NewError(fiber.StatusBadRequest, "iters must be 1..1000")
+	}
+
+	var img [][]float64
+	switch req.Pattern {
+	case "zeros":
+		img = makeImage(s.InputW, s.InputH, 0)
+	case "ones":
+		img = makeImage(s.InputW, s.InputH, 1)
+	case "random":
+		img = makeImage(s.InputW, s.InputH, 0)
+		for _, row := range img {
+			for x := range row {
+				row[x] = rand.Float64()
+			}
+		}
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "pattern must be zeros, ones or random")
+	}
+
+	m := s.primaryModel()
+	ctx := c.UserContext()
+	limit, _ := ctx.Deadline()
+	minMs, maxMs, sum := math.MaxFloat64, 0.0, 0.0
+	for i := 0; i < req.Iters; i++ {
+		if err := s.throttle.take(ctx, limit, 1); err != nil {
+			err = acquireError(err)
+			s.setRetryAfter(c, err) // admin routes aren't tracked
+			return err
+		}
+		t0 := time.Now()
+		if _, _, err := s.forward(m, img); err != nil {
+			return forwardError(err)
+		}
+		ms := durMs(time.Since(t0))
+		minMs, maxMs, sum = math.Min(minMs, ms), math.Max(maxMs, ms), sum+ms
+	}
+	return c.JSON(fiber.Map{
+		"model":   m.ModelName,
+		"iters":   req.Iters,
+		"pattern": req.Pattern,
+		"min_ms":  minMs,
+		"avg_ms":  sum / float64(req.Iters),
+		"max_ms":  maxMs,
+		"gpu":     m.GPU,
+	})
+}
+
+type probeReq struct {
+	MaxMs float64 `json:"max_ms"` // latency ceiling per batch (default 1000)
+	Limit int     `json:"limit"`  // largest batch tried (default 4096)
+}
+
+type probeStep struct {
+	Batch     int     `json:"batch"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	OOM       bool    `json:"oom,omitempty"` // the GPU ran out of memory
+}
+
+// handleProbeBatch doubles the batch size, running each batch on the
+// primary model's GPU path, until a forward fails or the batch takes longer
+// than max_ms. A failed probe resets the model's GPU state before returning.
+func (s *Server) handleProbeBatch(c *fiber.Ctx) error {
+	var req probeReq
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	if req.MaxMs <= 0 {
+		req.MaxMs = 1000
+	}
+	if req.Limit <= 0 {
+		req.Limit = 4096
+	}
+
+	// -timeout bounds the wait for a slot; the probe itself may run longer.
+	ctx, cancel := s.withDeadline(c.UserContext())
+	defer cancel()
+	slot, _, err := s.acquire(ctx, prioNormal)
+	if err != nil {
+		return acquireError(err)
+	}
+	defer s.release(slot)
+
+	m := s.primaryModel()
+	img := makeImage(s.InputW, s.InputH, 0)
+	var steps []probeStep
+	best := 0
+	for n := 1; n <= req.Limit; n *= 2 {
+		t0 := time.Now()
+		err := s.probeOnce(m, img, n)
+		step := probeStep{Batch: n, LatencyMs: durMs(time.Since(t0))}
+		if err != nil {
+			step.Error = err.Error()
+			if isGPUOOM(err) {
+				step.OOM = true
+				atomic.AddInt64(&s.stats.gpuOOM, 1)
+			}
+			steps = append(steps, step)
+			s.resetGPU(m)
+			break
+		}
+		steps = append(steps, step)
+		if step.LatencyMs > req.MaxMs {
+			break
+		}
+		best = n
+	}
+	return c.JSON(fiber.Map{
+		"recommended_max_batch": best,
+		"current_max_batch":     s.maxBatch,
+		"max_ms":                req.MaxMs,
+		"steps":                 steps,
+		"model":                 m.ModelName,
+		"gpu":                   m.GPU,
+	})
+}
+
+// probeOnce runs n forwards of img through m. On a GPU model they go
+// straight to gpuForward: forward would recompute a failed sample on CPU,
+// hiding the out-of-memory error the probe is looking for.
+func (s *Server) probeOnce(m *Model, img [][]float64, n int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("forward panic: %v", r)
+		}
+	}()
+	for i := 0; i < n; i++ {
+		if err := s.probeForward(m, img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) probeForward(m *Model, img [][]float64) error {
+	if !m.GPU {
+		_, _, err := s.forward(m, img)
+		return err
+	}
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	if !m.NN.WebGPUNative {
+		return errors.New("model was retired by a reload")
+	}
+	if err := gpuForward(m.NN, img); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.stats.gpuForwards, 1)
+	return nil
+}
+
+// resetGPU tears down and rebuilds m's GPU pipelines under gpuMu. A model
+// a reload retired since the caller read it is left alone: rebuilding its
+// pipelines would put the old network back on the device.
+func (s *Server) resetGPU(m *Model) {
+	if !m.GPU {
+		return
+	}
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	if !m.NN.WebGPUNative {
+		return
+	}
+	m.NN.CleanupOptimizedGPU()
+	if err := m.NN.InitializeOptimizedGPU(); err != nil {
+		log.Printf("WARN: GPU reset for %s failed: %v", m.ModelName, err)
+		return
+	}
+	log.Printf("GPU state reset for %s.", m.ModelName)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Dataset input analysis (no inference)
+// ─────────────────────────────────────────────────────────────
+
+type analyzeReq struct {
+	Batch  [][]float64   `json:"batch"`  // N × (w*h), as /infer-batch
+	Images [][][]float64 `json:"images"` // N × h × w
+	Bins   int           `json:"bins"`   // histogram bins (default 10)
+}
+
+type histogram struct {
+	Edges  []float64 `json:"edges"`  // bins+1 bin boundaries
+	Counts []int     `json:"counts"` // values per bin
+}
+
+type valueStats struct {
+	Count      int       `json:"count"`
+	Min        float64   `json:"min"`
+	Max        float64   `json:"max"`
+	Mean       float64   `json:"mean"`
+	Std        float64   `json:"std"`
+	OutOfRange int       `json:"out_of_range"` // values outside [0,1]
+	Histogram  histogram `json:"histogram"`
+}
+
+type analyzeResp struct {
+	N      int           `json:"n"`     // samples that passed preprocessing
+	Raw    valueStats    `json:"raw"`   // values as sent, every sample
+	Input  valueStats    `json:"input"` // values the model would receive
+	Mean   [][]float64   `json:"mean"`  // h×w per-pixel mean of the model input
+	Std    [][]float64   `json:"std"`   // h×w per-pixel standard deviation
+	Errors []sampleError `json:"errors,omitempty"`
+}
+
+// handleAnalyze summarizes a sample of inputs so a client can check its
+// preprocessing against the model's expectations across a dataset: "raw"
+// shows what was sent (0–255 pixels stand out in max and out_of_range),
+// "input" and the per-pixel maps show what the server's preset and
+// clamping turn it into. Samples preprocessing rejects are listed under
+// errors and only count towards raw. Nothing is forwarded.
+func (s *Server) handleAnalyze(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	var req analyzeReq
+	if err := parseBody(c, &req, "'batch' or 'images'"); err != nil {
+		return err
+	}
+	if req.Bins == 0 {
+		req.Bins = 10
+	}
+	if req.Bins < 1 || req.Bins > 100 {
+		return fiber.NewError(fiber.StatusBadRequest, "bins must be 1..100")
+	}
+	total := max(len(req.Images), len(req.Batch))
+	if total == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "provide 'images' or 'batch'")
+	}
+	if total > s.maxBatch {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("batch of %d exceeds -max-batch %d", total, s.maxBatch))
+	}
+
+	var (
+		raw     []float64
+		imgs    [][][]float64
+		invalid []sampleError
+	)
+	for i := 0; i < total; i++ {
+		var (
+			img [][]float64
+			err error
+		)
+		if len(req.Images) > 0 {
+			for _, row := range req.Images[i] {
+				raw = append(raw, row...)
+			}
+			img, err = s.normalizeInput(inferReq{Image: req.Images[i]})
+		} else {
+			raw = append(raw, req.Batch[i]...)
+			img, err = s.reshape(req.Batch[i])
+		}
+		if err != nil {
+			invalid = append(invalid, sampleError{Index: i, Error: err.Error()})
+			continue
+		}
+		imgs = append(imgs, img)
+	}
+
+	resp := analyzeResp{N: len(imgs), Raw: summarize(raw, req.Bins), Errors: invalid}
+	if len(imgs) == 0 {
+		return c.JSON(resp)
+	}
+	var seen []float64
+	resp.Mean, resp.Std = make([][]float64, s.InputH), make([][]float64, s.InputH)
+	for r := 0; r < s.InputH; r++ {
+		resp.Mean[r], resp.Std[r] = make([]float64, s.InputW), make([]float64, s.InputW)
+		for col := 0; col < s.InputW; col++ {
+			var sum, sq float64
+			for _, img := range imgs {
+				v := img[r][col]
+				sum += v
+				sq += v * v
+				seen = append(seen, v)
+			}
+			n := float64(len(imgs))
+			mean := sum / n
+			resp.Mean[r][col] = mean
+			resp.Std[r][col] = math.Sqrt(math.Max(sq/n-mean*mean, 0))
+		}
+	}
+	resp.Input = summarize(seen, req.Bins)
+	return c.JSON(resp)
+}
+
+// summarize computes vs's range, moments and a histogram. The bins span
+// [0,1] widened to the data's range, so in-range data gets even steps and
+// stray values still land in a bin.
+func summarize(vs []float64, bins int) valueStats {
+	st := valueStats{Count: len(vs)}
+	if len(vs) == 0 {
+		return st
+	}
+	st.Min, st.Max = math.Inf(1), math.Inf(-1)
+	var sum, sq float64
+	for _, v := range vs {
+		st.Min, st.Max = math.Min(st.Min, v), math.Max(st.Max, v)
+		sum += v
+		sq += v * v
+		if v < 0 || v > 1 {
+			st.OutOfRange++
+		}
+	}
+	n := float64(len(vs))
+	st.Mean = sum / n
+	st.Std = math.Sqrt(math.Max(sq/n-st.Mean*st.Mean, 0))
+
+	lo, hi := math.Min(st.Min, 0), math.Max(st.Max, 1)
+	step := (hi - lo) / float64(bins)
+	st.Histogram.Edges = make([]float64, bins+1)
+	for i := range st.Histogram.Edges {
+		st.Histogram.Edges[i] = lo + float64(i)*step
+	}
+	st.Histogram.Edges[bins] = hi
+	st.Histogram.Counts = make([]int, bins)
+	for _, v := range vs {
+		b := int((v - lo) / step)
+		st.Histogram.Counts[min(max(b, 0), bins-1)]++
+	}
+	return st
+}
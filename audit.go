@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Hash-chained inference audit log (-audit-log)
+// ─────────────────────────────────────────────────────────────
+
+const auditBuffer = 1024
+
+// auditGenesis is the prev of the first record.
+var auditGenesis = strings.Repeat("0", 2*sha256.Size)
+
+type auditRecord struct {
+	Seq       int64     `json:"seq"`
+	When      time.Time `json:"when"`
+	Model     string    `json:"model"`
+	ModelSHA  string    `json:"model_sha256"`
+	InputHash string    `json:"input_hash"` // of the model input, after preprocessing
+	TopIndex  int       `json:"top_index"`
+	TopLabel  string    `json:"top_label,omitempty"`
+	TopScore  float64   `json:"top_score"`
+	Prev      string    `json:"prev"` // hash of the record before
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// digest is the SHA-256 of the record's JSON without its hash. Prev is part
+// of it, so editing, dropping or reordering any record breaks every hash
+// after it.
+func (r auditRecord) digest() string {
+	r.Hash = ""
+	b, _ := json.Marshal(r)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditLog appends one record per forward to a JSONL file from a
+// background goroutine, chaining each to the one before. Unlike the
+// sampler it never drops a record: when the buffer is full, requests wait
+// for the writer, since a gap is just what the log exists to rule out.
+type auditLog struct {
+	path   string
+	ch     chan auditRecord
+	done   chan struct{}
+	mu     sync.RWMutex // guards closing ch against late senders
+	closed bool
+
+	wmu  sync.Mutex // the writer's file state, for verify
+	w    *bufio.Writer
+	seq  int64
+	head string
+
+	blocked int64 // records that waited for buffer space
+}
+
+// openAuditLog opens path for appending and resumes its chain. A record cut
+// short by a crash is truncated away; a chain that doesn't verify fails
+// startup rather than being extended.
+func openAuditLog(path string) (*auditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	res, err := verifyAudit(f, 0, "")
+	if err == nil && !res.OK {
+		err = fmt.Errorf("%s does not verify at line %d: %s", path, res.BadLine, res.Error)
+	}
+	if err == nil && res.PartialTail {
+		log.Printf("WARN: audit log %s: dropping an incomplete last record.", path)
+		err = f.Truncate(res.size)
+	}
+	if err == nil {
+		_, err = f.Seek(res.size, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	al := &auditLog{
+		path: path,
+		ch:   make(chan auditRecord, auditBuffer),
+		done: make(chan struct{}),
+		w:    bufio.NewWriter(f),
+		seq:  res.Records,
+		head: res.Head,
+	}
+	go al.run(f)
+	return al, nil
+}
+
+func (al *auditLog) run(f *os.File) {
+	defer close(al.done)
+	defer f.Close()
+	for rec := range al.ch {
+		al.wmu.Lock()
+		rec.Seq, rec.Prev = al.seq+1, al.head
+		rec.Hash = rec.digest()
+		b, err := json.Marshal(rec)
+		if err == nil {
+			_, err = al.w.Write(append(b, '\n'))
+		}
+		if err != nil {
+			log.Printf("WARN: audit write: %v", err)
+		} else {
+			al.seq, al.head = rec.Seq, rec.Hash
+		}
+		// Flush once the burst is written, so the file trails the
+		// chain by at most the records still queued.
+		if len(al.ch) == 0 {
+			if err := al.w.Flush(); err != nil {
+				log.Printf("WARN: audit flush: %v", err)
+			}
+		}
+		al.wmu.Unlock()
+	}
+	al.wmu.Lock()
+	_ = al.w.Flush()
+	al.wmu.Unlock()
+}
+
+// record logs one prediction. Safe on a nil log.
+func (al *auditLog) record(m *Model, img [][]float64, out []float64) {
+	if al == nil {
+		return
+	}
+	k := hashInput(m.ModelName, img)
+	idx := argmax64(out)
+	rec := auditRecord{
+		When:      time.Now().UTC(),
+		Model:     m.ModelName,
+		ModelSHA:  m.SHA256,
+		InputHash: hex.EncodeToString(k[:]),
+		TopIndex:  idx,
+		TopLabel:  m.label(idx),
+		TopScore:  m.probs(out)[idx],
+	}
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	if al.closed {
+		return
+	}
+	select {
+	case al.ch <- rec:
+	default:
+		atomic.AddInt64(&al.blocked, 1)
+		al.ch <- rec
+	}
+}
+
+// close writes the queued records; later record calls are ignored.
+func (al *auditLog) close() {
+	if al == nil {
+		return
+	}
+	al.mu.Lock()
+	al.closed = true
+	close(al.ch)
+	al.mu.Unlock()
+	<-al.done
+}
+
+// written flushes and returns the last record written and its hash.
+func (al *auditLog) written() (int64, string) {
+	al.wmu.Lock()
+	defer al.wmu.Unlock()
+	_ = al.w.Flush()
+	return al.seq, al.head
+}
+
+func (al *auditLog) snapshot() fiber.Map {
+	al.wmu.Lock()
+	defer al.wmu.Unlock()
+	return fiber.Map{
+		"path":    al.path,
+		"records": al.seq,
+		"head":    al.head,
+		"queued":  len(al.ch),
+		"blocked": atomic.LoadInt64(&al.blocked),
+	}
+}
+
+type auditResult struct {
+	OK          bool   `json:"ok"`
+	Records     int64  `json:"records"`
+	Head        string `json:"head"` // hash of the last record
+	BadLine     int64  `json:"bad_line,omitempty"`
+	Error       string `json:"error,omitempty"`
+	PartialTail bool   `json:"partial_tail,omitempty"` // a last line still being written
+	size        int64  // bytes of complete lines
+}
+
+// verifyAudit walks the chain in r. With wantSeq > 0, the record with that
+// seq must also carry wantHead: the chain alone can't tell a log whose last
+// records were cut off from one that was never longer.
+func verifyAudit(r io.Reader, wantSeq int64, wantHead string) (auditResult, error) {
+	res := auditResult{OK: true, Head: auditGenesis}
+	br := bufio.NewReader(r)
+	matched := wantSeq == 0
+	for line := int64(1); ; line++ {
+		b, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			res.PartialTail = len(bytes.TrimSpace(b)) > 0
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		fail := func(msg string) (auditResult, error) {
+			res.OK, res.BadLine, res.Error = false, line, msg
+			return res, nil
+		}
+		var rec auditRecord
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rec); err != nil {
+			return fail("unreadable record: " + err.Error())
+		}
+		switch {
+		case rec.Seq != res.Records+1:
+			return fail(fmt.Sprintf("seq %d follows seq %d", rec.Seq, res.Records))
+		case rec.Prev != res.Head:
+			return fail("prev does not match the hash of the record before")
+		case rec.Hash != rec.digest():
+			return fail("hash does not match the record's contents")
+		}
+		res.Records, res.Head = rec.Seq, rec.Hash
+		res.size += int64(len(b))
+		if rec.Seq == wantSeq {
+			if rec.Hash != wantHead {
+				return fail(fmt.Sprintf("seq %d is not the record the server wrote", rec.Seq))
+			}
+			matched = true
+		}
+	}
+	if !matched {
+		res.OK = false
+		res.Error = fmt.Sprintf("log ends at seq %d but the server has written %d records", res.Records, wantSeq)
+	}
+	return res, nil
+}
+
+// handleAuditVerify re-reads the audit log and checks every hash link, and
+// that the file still holds the last record this process wrote.
+func (s *Server) handleAuditVerify(c *fiber.Ctx) error {
+	if s.audit == nil {
+		return fiber.NewError(fiber.StatusNotFound, "audit log is off; start the server with -audit-log")
+	}
+	seq, head := s.audit.written()
+	f, err := os.Open(s.audit.path)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	defer f.Close()
+	res, err := verifyAudit(f, seq, head)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(res)
+}
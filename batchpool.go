@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Parallel batch forwards on CPU models (-batch-workers)
+// ─────────────────────────────────────────────────────────────
+
+// batchWorkers is set by -batch-workers before any model is mounted. 1 keeps
+// batches sequential.
+var batchWorkers = 1
+
+// batchReplicas builds the pool of networks a CPU model's batches run on
+// in parallel. A paragon network keeps its activations in its neurons, so
+// each worker needs a copy of its own, taken from the pool for the length
+// of a batch. GPU models get none: their forwards are serialized on the
+// device anyway.
+func batchReplicas(nn *paragon.Network[float32]) (chan *paragon.Network[float32], error) {
+	pool := make(chan *paragon.Network[float32], batchWorkers)
+	for range batchWorkers {
+		rep, err := rebuildNetwork(nn)
+		if err != nil {
+			return nil, err
+		}
+		rep.WebGPUNative = false
+		pool <- rep
+	}
+	return pool, nil
+}
+
+// batchForwards runs imgs in order and returns their outputs by index, and
+// whether every forward used the GPU. A CPU model with replicas spreads
+// them over up to -batch-workers goroutines (no more than GOMAXPROCS)
+// instead, outside gpuMu; concurrent batches share the pool.
+func (s *Server) batchForwards(ctx context.Context, m *Model, imgs [][][]float64) ([][]float64, bool, error) {
+	outs := make([][]float64, len(imgs))
+	if m.batchPool == nil || len(imgs) < 2 {
+		allGPU := true
+		for i, img := range imgs {
+			t0 := time.Now()
+			out, usedGPU, err := s.forwardCtx(ctx, m, img)
+			if err != nil {
+				return nil, false, err
+			}
+			s.stats.observe(time.Since(t0))
+			s.sampler.maybe(m, img, out)
+			s.audit.record(m, img, out)
+			allGPU = allGPU && usedGPU
+			outs[i] = out
+		}
+		return outs, allGPU, nil
+	}
+
+	var (
+		next    atomic.Int64 // index of the next image to take
+		errOnce sync.Once
+		firstE  error
+		failed  atomic.Bool
+		wg      sync.WaitGroup
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { firstE = err })
+		failed.Store(true)
+	}
+	// More workers than cores only adds switching between replicas that
+	// don't fit in cache together.
+	for range min(cap(m.batchPool), len(imgs), runtime.GOMAXPROCS(0)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nn := <-m.batchPool
+			defer func() { m.batchPool <- nn }()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= len(imgs) {
+					return
+				}
+				if ctx.Err() != nil {
+					fail(ctxErr(ctx))
+					return
+				}
+				t0 := time.Now()
+				out, err := poolForward(nn, imgs[i])
+				if err != nil {
+					fail(err)
+					return
+				}
+				atomic.AddInt64(&s.stats.cpuForwards, 1)
+				s.stats.observe(time.Since(t0))
+				s.sampler.maybe(m, imgs[i], out)
+				s.audit.record(m, imgs[i], out)
+				outs[i] = out
+			}
+		}()
+	}
+	wg.Wait()
+	if firstE != nil {
+		return nil, false, firstE
+	}
+	return outs, false, nil
+}
+
+// poolForward runs img on a pool replica. The forward runs on a worker
+// goroutine, out of reach of Fiber's recover middleware, so a panic on a
+// bad sample is turned into an error here instead of ending the process.
+func poolForward(nn *paragon.Network[float32], img [][]float64) (out []float64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("forward panic: %v", r)
+		}
+	}()
+	nn.Forward(img)
+	if out = nn.ExtractOutput(); len(out) == 0 {
+		return nil, errNoOutput
+	}
+	return out, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// GPU circuit breaker
+// ─────────────────────────────────────────────────────────────
+
+var (
+	errBreakerOpen = errors.New("GPU circuit breaker open")
+	errNoOutput    = errors.New("model produced no output")
+)
+
+// forwardError maps a forward failure to its HTTP status; a request whose
+// deadline passed between forwards is a 504, as in acquireError.
+func forwardError(err error) error {
+	if errors.Is(err, errBreakerOpen) || errors.Is(err, errCanceled) {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	if errors.Is(err, errDeadline) {
+		return fiber.NewError(fiber.StatusGatewayTimeout, err.Error())
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (b breakerState) String() string {
+	switch b {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// breaker opens after threshold GPU failures inside window. While open,
+// forwards go to CPU (or fail fast with failFast); after cooldown one
+// forward is let through half-open to test whether the GPU recovered.
+type breaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	failFast  bool
+
+	mu       sync.Mutex
+	state    breakerState
+	failures []time.Time
+	openedAt time.Time
+	lastErr  string
+	trips    int
+}
+
+// allow reports whether the next forward may use the GPU.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		b.state = breakerHalfOpen
+		log.Printf("GPU breaker half-open: probing GPU")
+	}
+	return b.state != breakerOpen
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		log.Printf("GPU breaker closed: GPU recovered")
+	}
+	b.state = breakerClosed
+	b.failures = b.failures[:0]
+}
+
+func (b *breaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.lastErr = err.Error()
+
+	kept := b.failures[:0]
+	for _, t := range b.failures {
+		if now.Sub(t) < b.window {
+			kept = append(kept, t)
+		}
+	}
+	b.failures = append(kept, now)
+
+	if b.state == breakerHalfOpen || len(b.failures) >= b.threshold {
+		b.state, b.openedAt = breakerOpen, now
+		b.failures = b.failures[:0]
+		b.trips++
+		log.Printf("GPU breaker open for %s: %v", b.cooldown, err)
+	}
+}
+
+func (b *breaker) stateName() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.String()
+}
+
+func (b *breaker) snapshot() fiber.Map {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return fiber.Map{
+		"state":      b.state.String(),
+		"failures":   len(b.failures),
+		"threshold":  b.threshold,
+		"trips":      b.trips,
+		"last_error": b.lastErr,
+		"fail_fast":  b.failFast,
+	}
+}
+
+// gpuForward runs the optimized GPU path directly so failures surface as
+// errors instead of Paragon's silent CPU fallback inside Forward.
+func gpuForward(nn *paragon.Network[float32], img [][]float64) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("GPU forward panic: %v", r)
+		}
+	}()
+	return nn.ForwardGPUOptimized(img)
+}
+
+// isGPUOOM reports whether a GPU forward failed on a device allocation,
+// as wgpu's OutOfMemory error or a driver's "out of memory" panic.
+func isGPUOOM(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "out of memory") || strings.Contains(msg, "outofmemory")
+}
+
+// warmupOutput runs one forward on a uniform fill image, turning a panic
+// from a malformed network into an error.
+func warmupOutput(nn *paragon.Network[float32], inW, inH int, fill float64) (out []float64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("warmup forward panic: %v", r)
+		}
+	}()
+	nn.Forward(makeImage(inW, inH, fill))
+	return nn.ExtractOutput(), nil
+}
+
+// cpuForward forces Paragon's CPU path. Callers must hold gpuMu since it
+// flips WebGPUNative for the duration of the call.
+func cpuForward(nn *paragon.Network[float32], img [][]float64) {
+	gpu := nn.WebGPUNative
+	nn.WebGPUNative = false
+	nn.Forward(img)
+	nn.WebGPUNative = gpu
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Model bundles (zip / tar / tar.gz)
+// ─────────────────────────────────────────────────────────────
+
+// bundleManifest is manifest.json inside a bundle. Every field is optional;
+// file names default to model.json and labels.json.
+type bundleManifest struct {
+	Model  string `json:"model"`
+	Labels string `json:"labels"`
+	Input  []int  `json:"input"` // [w, h], must match the first-layer shape
+}
+
+// parsedBundle is a bundle read and parsed but not yet mounted.
+type parsedBundle struct {
+	nn                *paragon.Network[float32]
+	inW, inH, classes int
+	labels            []string
+	meta              []map[string]string
+	sha               string     // of the network JSON
+	quant             *quantInfo // nil for float models
+}
+
+// loadBundle loads model, labels and manifest from one archive so they can
+// only ever be deployed together.
+func loadBundle(p string, gpu sync.Locker) (*Model, error) {
+	b, err := parseBundle(p)
+	if err != nil {
+		return nil, err
+	}
+	return b.mount(p, gpu)
+}
+
+func (b *parsedBundle) mount(p string, gpu sync.Locker) (*Model, error) {
+	m, err := mountModel(b.nn, b.inW, b.inH, b.classes, p, gpu)
+	if err != nil {
+		return nil, err
+	}
+	m.Labels, m.LabelMeta, m.SHA256, m.Quant = b.labels, b.meta, b.sha, b.quant
+	return m, nil
+}
+
+// parseBundle reads and validates a bundle without touching the GPU.
+func parseBundle(p string) (*parsedBundle, error) {
+	files, err := readArchive(p)
+	if err != nil {
+		return nil, fmt.Errorf("bundle %s: %w", filepath.Base(p), err)
+	}
+
+	var man bundleManifest
+	if b, ok := files["manifest.json"]; ok {
+		if err := json.Unmarshal(b, &man); err != nil {
+			return nil, fmt.Errorf("bundle manifest: %w", err)
+		}
+	}
+	if man.Model == "" {
+		man.Model = "model.json"
+	}
+	if man.Labels == "" {
+		man.Labels = "labels.json"
+	}
+
+	modelJSON, ok := files[man.Model]
+	if !ok {
+		return nil, fmt.Errorf("bundle %s has no %s", filepath.Base(p), man.Model)
+	}
+	pb, err := parseParagonModel(modelJSON)
+	if err != nil {
+		return nil, err
+	}
+	// Paragon's forward indexes the input by the first layer's shape, so a
+	// manifest can only confirm it, not reshape it.
+	if len(man.Input) > 0 && (len(man.Input) != 2 || man.Input[0] != pb.inW || man.Input[1] != pb.inH) {
+		return nil, fmt.Errorf("bundle manifest input %v does not match first layer %dx%d", man.Input, pb.inW, pb.inH)
+	}
+
+	if b, ok := files[man.Labels]; ok {
+		if pb.labels, pb.meta, err = parseLabels(b, pb.classes); err != nil {
+			return nil, err
+		}
+	}
+	return pb, nil
+}
+
+// readArchive returns the regular files of a zip or (gzipped) tar keyed by
+// their slash path, with a single leading directory stripped.
+func readArchive(p string) (map[string][]byte, error) {
+	raw, err := os.ReadFile(filepath.Clean(p))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	lower := strings.ToLower(p)
+
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			files[f.Name] = b
+		}
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		var r io.Reader = bytes.NewReader(raw)
+		if !strings.HasSuffix(lower, ".tar") {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if h.Typeflag != tar.TypeReg {
+				continue
+			}
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			files[strings.TrimPrefix(h.Name, "./")] = b
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive type (want .zip, .tar, .tar.gz or .tgz)")
+	}
+
+	return stripCommonDir(files), nil
+}
+
+// stripCommonDir drops a top-level directory shared by every entry, so
+// archives created as "bundle/model.json" work the same as flat ones.
+func stripCommonDir(files map[string][]byte) map[string][]byte {
+	dir := ""
+	for name := range files {
+		d, _, found := strings.Cut(name, "/")
+		if !found || (dir != "" && d != dir) {
+			return files
+		}
+		dir = d
+	}
+	out := make(map[string][]byte, len(files))
+	for name, b := range files {
+		out[path.Clean(strings.TrimPrefix(name, dir+"/"))] = b
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Calibration (reliability diagram + ECE)
+// ─────────────────────────────────────────────────────────────
+
+type calibrateReq struct {
+	Samples []struct {
+		Input    flatInput       `json:"input"`
+		Image    imageRows       `json:"image"`
+		Expected json.RawMessage `json:"expected_label"` // class index or label name
+	} `json:"samples"`
+	Bins     int    `json:"bins"`     // confidence bins over [0,1] (default 10)
+	Priority string `json:"priority"` // high | normal (default) | low
+}
+
+type calibrationBin struct {
+	Lo         float64 `json:"lo"`
+	Hi         float64 `json:"hi"`
+	Count      int     `json:"count"`
+	Accuracy   float64 `json:"accuracy"`   // fraction predicted correctly
+	Confidence float64 `json:"confidence"` // mean top-class probability
+}
+
+type calibrateResp struct {
+	N              int              `json:"n"`
+	Accuracy       float64          `json:"accuracy"`
+	MeanConfidence float64          `json:"mean_confidence"`
+	ECE            float64          `json:"ece"` // Σ count/n · |accuracy − confidence|
+	Bins           []calibrationBin `json:"bins"`
+	Model          string           `json:"model"`
+	LatencyMs      float64          `json:"latency_ms"`
+}
+
+// handleCalibrate runs a labeled set through the active model, in
+// -max-batch chunks on the batch path as replay does, and bins the
+// predictions by confidence: the softmaxed top-class probability, as
+// min_confidence uses. A well-calibrated model's per-bin accuracy tracks
+// its mean confidence; ECE sums the gap weighted by bin size.
+func (s *Server) handleCalibrate(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	var req calibrateReq
+	if err := parseBody(c, &req, "'samples'"); err != nil {
+		return err
+	}
+	if len(req.Samples) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "provide 'samples' of {input|image, expected_label}")
+	}
+	if req.Bins == 0 {
+		req.Bins = 10
+	}
+	if req.Bins < 1 || req.Bins > 100 {
+		return fiber.NewError(fiber.StatusBadRequest, "bins must be 1..100")
+	}
+	prio, err := parsePriority(req.Priority)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	m := s.activeModel()
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	trackModel(c, m.ModelName)
+	imgs := make([][][]float64, len(req.Samples))
+	expected := make([]int, len(req.Samples))
+	for i, smp := range req.Samples {
+		if imgs[i], err = s.normalizeInput(inferReq{Input: smp.Input, Image: smp.Image, Model: m.ModelName}); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sample %d: %v", i, err))
+		}
+		if expected[i], err = m.classOf(smp.Expected); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sample %d: %v", i, err))
+		}
+	}
+
+	start := time.Now()
+	bins := make([]calibrationBin, req.Bins)
+	for b := range bins {
+		bins[b].Lo, bins[b].Hi = float64(b)/float64(req.Bins), float64(b+1)/float64(req.Bins)
+	}
+	var correct int
+	var confSum float64
+	for lo := 0; lo < len(imgs); lo += s.maxBatch {
+		hi := min(lo+s.maxBatch, len(imgs))
+		slot, _, err := s.acquireN(c.UserContext(), prio, nil, hi-lo)
+		if err != nil {
+			return acquireError(err)
+		}
+		s.stats.observeSlot(slot)
+		resp, err := s.runBatch(c.UserContext(), m, imgs[lo:hi], slot, batchReq{})
+		s.release(slot)
+		if err != nil {
+			return err
+		}
+		for j, p := range resp.Probs.([][]float64) {
+			if !resp.Softmax {
+				p = m.probs(p)
+			}
+			idx := argmax64(p)
+			conf := p[idx]
+			b := min(int(conf*float64(req.Bins)), req.Bins-1)
+			bins[b].Count++
+			bins[b].Confidence += conf
+			confSum += conf
+			if idx == expected[lo+j] {
+				bins[b].Accuracy++
+				correct++
+			}
+		}
+	}
+
+	n := float64(len(imgs))
+	resp := calibrateResp{
+		N:              len(imgs),
+		Accuracy:       float64(correct) / n,
+		MeanConfidence: confSum / n,
+		Bins:           bins,
+		Model:          m.ModelName,
+		LatencyMs:      durMs(time.Since(start)),
+	}
+	for b := range bins {
+		if k := float64(bins[b].Count); k > 0 {
+			bins[b].Accuracy /= k
+			bins[b].Confidence /= k
+			resp.ECE += k / n * math.Abs(bins[b].Accuracy-bins[b].Confidence)
+		}
+	}
+	return c.JSON(resp)
+}
+
+// classOf resolves an expected label: a class index, or a label name
+// (a numeric string is an index when the model has no labels).
+func (m *Model) classOf(raw json.RawMessage) (int, error) {
+	var idx int
+	if err := json.Unmarshal(raw, &idx); err == nil {
+		if idx < 0 || idx >= m.ClassCount {
+			return 0, fmt.Errorf("expected_label %d is outside 0..%d", idx, m.ClassCount-1)
+		}
+		return idx, nil
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		return 0, fmt.Errorf("expected_label must be a class index or label name")
+	}
+	for i, l := range m.Labels {
+		if l == name && name != "" {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(name); err == nil && len(m.Labels) == 0 && i >= 0 && i < m.ClassCount {
+		return i, nil
+	}
+	return 0, fmt.Errorf("unknown expected_label %q", name)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Blast input corpus (-blast-corpus)
+// ─────────────────────────────────────────────────────────────
+
+// loadCorpus reads a JSONL file of inputs for /blast to cycle through.
+// Each line is a flattened input array or an object with "input" or
+// "image", preprocessed once here exactly as /infer would.
+func (s *Server) loadCorpus(path string) ([][][]float64, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out [][][]float64
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req inferReq
+		if line[0] == '[' {
+			err = json.Unmarshal(line, &req.Input)
+		} else {
+			err = json.Unmarshal(line, &req)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		img, err := s.normalizeInput(req)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		out = append(out, img)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no inputs in %s", path)
+	}
+	return out, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import "sync/atomic"
+
+// ─────────────────────────────────────────────────────────────
+// GPU + CPU load split (-cpu-share)
+// ─────────────────────────────────────────────────────────────
+
+// cpuShare is set by -cpu-share before any model is mounted and never
+// changes afterwards.
+var cpuShare float64
+
+// routeToCPU reports whether the next forward goes to the CPU replica.
+// Forwards are counted rather than sampled, so every 1/cpuShare-th one
+// goes, evenly spread under any load.
+func (s *Server) routeToCPU() bool {
+	n := float64(s.cpuRouted.Add(1))
+	return int64(n*cpuShare) > int64((n-1)*cpuShare)
+}
+
+// replicaForward runs img on m's CPU replica. It takes m.cpuMu instead of
+// gpuMu, so it overlaps with GPU forwards on m.NN.
+func (s *Server) replicaForward(m *Model, img [][]float64) ([]float64, bool, error) {
+	m.cpuMu.Lock()
+	defer m.cpuMu.Unlock()
+	m.CPU.Forward(img)
+	atomic.AddInt64(&s.stats.cpuForwards, 1)
+	out := m.CPU.ExtractOutput()
+	if len(out) == 0 {
+		return nil, false, errNoOutput
+	}
+	return out, false, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// In-flight request deduplication
+// ─────────────────────────────────────────────────────────────
+
+type inputKey [sha256.Size]byte
+
+// hashInput keys an image by model and exact float bits.
+func hashInput(model string, img [][]float64) inputKey {
+	h := sha256.New()
+	h.Write([]byte(model))
+	var buf [8]byte
+	for _, row := range img {
+		for _, v := range row {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+			h.Write(buf[:])
+		}
+	}
+	var k inputKey
+	h.Sum(k[:0])
+	return k
+}
+
+var errSharedPanic = errors.New("forward panicked in the identical request this one was waiting on")
+
+type flight struct {
+	done chan struct{}
+	out  []float64
+	gpu  bool
+	err  error
+}
+
+// flightGroup lets concurrent identical forwards share one result, in the
+// style of golang.org/x/sync/singleflight.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[inputKey]*flight
+	shared  int64 // forwards skipped because another caller ran them
+}
+
+func (g *flightGroup) do(key inputKey, fn func() ([]float64, bool, error)) (out []float64, gpu, shared bool, err error) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		atomic.AddInt64(&g.shared, 1)
+		return append([]float64(nil), f.out...), f.gpu, true, f.err
+	}
+	if g.flights == nil {
+		g.flights = map[inputKey]*flight{}
+	}
+	// errSharedPanic stands until fn returns, so if it panics the waiters
+	// get an error while the panic goes on up the leader's stack.
+	f := &flight{done: make(chan struct{}), err: errSharedPanic}
+	g.flights[key] = f
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+
+	f.out, f.gpu, f.err = fn()
+	return append([]float64(nil), f.out...), f.gpu, false, f.err
+}
+
+// forwardDedup is forwardCtx, except identical inputs already in flight on
+// m wait for that forward instead of running their own.
+func (s *Server) forwardDedup(ctx context.Context, m *Model, img [][]float64) ([]float64, bool, bool, error) {
+	if ctx.Err() != nil {
+		return nil, false, false, ctxErr(ctx)
+	}
+	return s.flights.do(hashInput(m.ModelName, img), func() ([]float64, bool, error) {
+		return s.forward(m, img)
+	})
+}
+
+// noCache reports whether a request must run its own forward: "no_cache"
+// in the body or Cache-Control: no-store / no-cache. Every result-sharing
+// path (today: dedup) checks this so benchmarks measure real compute; the
+// forced forward still goes through s.forward and lands in the stats.
+func noCache(c *fiber.Ctx, flag bool) bool {
+	if flag {
+		return true
+	}
+	cc := strings.ToLower(c.Get(fiber.HeaderCacheControl))
+	return strings.Contains(cc, "no-store") || strings.Contains(cc, "no-cache")
+}
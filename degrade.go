@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Load-based degradation to a fallback model
+// ─────────────────────────────────────────────────────────────
+
+const (
+	degradeTick   = time.Second
+	degradeWindow = 10 * time.Second // p99 is computed over this window
+	maxSwitchLog  = 32
+)
+
+type switchEvent struct {
+	At     apiTime `json:"at"`
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Reason string  `json:"reason"`
+}
+
+// degrader flips requests to a smaller model when queue depth or p99
+// crosses its thresholds, and back once both fall under half of them.
+type degrader struct {
+	fallback *Model
+	maxQueue int
+	maxP99   float64
+
+	active atomic.Bool // serving from fallback
+
+	mu     sync.Mutex
+	events []switchEvent
+}
+
+func (d *degrader) run(s *Server) {
+	t := time.NewTicker(degradeTick)
+	defer t.Stop()
+	for range t.C {
+		q := atomic.LoadInt64(&s.queued)
+		_, _, p99, _ := s.stats.percentiles(time.Now().Add(-degradeWindow))
+
+		switch {
+		case !d.active.Load() && (q >= int64(d.maxQueue) || p99 >= d.maxP99):
+			d.flip(true, s.primaryModel().ModelName, d.fallback.ModelName, fmt.Sprintf("queue=%d p99=%.1fms", q, p99))
+		case d.active.Load() && q <= int64(d.maxQueue)/2 && p99 < d.maxP99/2:
+			d.flip(false, d.fallback.ModelName, s.primaryModel().ModelName, fmt.Sprintf("queue=%d p99=%.1fms", q, p99))
+		}
+	}
+}
+
+func (d *degrader) flip(toFallback bool, from, to, reason string) {
+	d.active.Store(toFallback)
+	log.Printf("Model switch %s → %s (%s)", from, to, reason)
+
+	d.mu.Lock()
+	d.events = append(d.events, switchEvent{At: apiTime(time.Now()), From: from, To: to, Reason: reason})
+	if len(d.events) > maxSwitchLog {
+		d.events = d.events[len(d.events)-maxSwitchLog:]
+	}
+	d.mu.Unlock()
+}
+
+func (d *degrader) snapshot() fiber.Map {
+	d.mu.Lock()
+	events := append([]switchEvent(nil), d.events...)
+	d.mu.Unlock()
+	return fiber.Map{
+		"model":     d.fallback.ModelName,
+		"active":    d.active.Load(),
+		"max_queue": d.maxQueue,
+		"max_p99":   d.maxP99,
+		"switches":  events,
+	}
+}
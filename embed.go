@@ -0,0 +1,125 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Penultimate-layer embeddings
+// ─────────────────────────────────────────────────────────────
+
+type embedResp struct {
+	TopIndex  int       `json:"top_index"`
+	TopLabel  string    `json:"top_label,omitempty"`
+	TopScore  float64   `json:"top_score"`
+	Embedding []float64 `json:"embedding"`
+	Dim       int       `json:"dim"`
+	Layer     int       `json:"layer"` // layer the embedding was read from
+	Model     string    `json:"model"`
+	LatencyMs float64   `json:"latency_ms"`
+}
+
+// embeddingLayer is the layer whose activations serve as the embedding: the
+// one feeding the output layer. A network with no hidden layer has none.
+func (m *Model) embeddingLayer() int {
+	if len(m.NN.Layers) < 3 {
+		return -1
+	}
+	return len(m.NN.Layers) - 2
+}
+
+// embeddingDim is the embedding length, 0 when the model has none.
+func (m *Model) embeddingDim() int {
+	l := m.embeddingLayer()
+	if l < 0 {
+		return 0
+	}
+	return m.NN.Layers[l].Width * m.NN.Layers[l].Height
+}
+
+// forwardEmbed runs img on CPU and returns the output and the embedding
+// layer's activations. The GPU path only reads back the output layer, so
+// hidden neuron values are stale after it.
+func (s *Server) forwardEmbed(m *Model, img [][]float64) ([]float64, []float64, error) {
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	cpuForward(m.NN, img)
+	out := m.NN.ExtractOutput()
+	if len(out) == 0 {
+		return nil, nil, errNoOutput
+	}
+	L := m.NN.Layers[m.embeddingLayer()]
+	emb := make([]float64, 0, L.Width*L.Height)
+	for _, row := range L.Neurons {
+		for _, n := range row {
+			emb = append(emb, float64(n.Value))
+		}
+	}
+	return out, emb, nil
+}
+
+// handleEmbed returns the prediction for one input together with its
+// penultimate-layer embedding, for similarity search on the client side.
+func (s *Server) handleEmbed(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	var req inferReq
+	if err := parseBody(c, &req, "'input' or 'image'"); err != nil {
+		return err
+	}
+	img, err := s.normalizeInput(req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	m, err := s.lookupModel(req.Model)
+	if err != nil {
+		return err
+	}
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	trackModel(c, m.ModelName)
+	if m.embeddingLayer() < 0 {
+		return fiber.NewError(fiber.StatusNotImplemented, "model "+m.ModelName+" has no hidden layer to embed from")
+	}
+	prio, err := parsePriority(req.Priority)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	slot, _, err := s.acquireIn(c.UserContext(), prio, s.lanes.infer)
+	if err != nil {
+		return acquireError(err)
+	}
+	defer s.releaseIn(slot, s.lanes.infer)
+	s.stats.observeSlot(slot)
+
+	start := time.Now()
+	out, emb, err := s.forwardEmbed(m, img)
+	if err != nil {
+		return forwardError(err)
+	}
+	lat := time.Since(start)
+	s.stats.observe(lat)
+	s.sampler.maybe(m, img, out)
+	s.audit.record(m, img, out)
+
+	idx := argmax64(out)
+	resp := embedResp{
+		TopIndex:  idx,
+		TopLabel:  m.label(idx),
+		TopScore:  out[idx],
+		Embedding: emb,
+		Dim:       len(emb),
+		Layer:     m.embeddingLayer(),
+		Model:     m.ModelName,
+		LatencyMs: durMs(lat),
+	}
+	if names := m.requestLabels(c); names != nil {
+		relabel(names, idx, &resp.TopLabel)
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Model registry & ensembles
+// ─────────────────────────────────────────────────────────────
+
+// loadRegistry loads the comma-separated -models paths next to the primary
+// model into reg. Every model must share the primary's input shape and
+// ClassCount so any of them (or all, as an ensemble) can serve the same
+// request. Each is loaded once even when -model-cache will evict it, so a
+// bad file fails startup rather than a request.
+func loadRegistry(primary *Model, paths string, reg *modelCache) ([]string, error) {
+	order := []string{primary.ModelName}
+	for _, p := range strings.Split(paths, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		m, err := loadModel(p, nil) // startup: nothing else is on the GPU yet
+		if err != nil {
+			return nil, err
+		}
+		if err := sameShape(primary, m); err != nil {
+			return nil, err
+		}
+		m.Labels, m.LabelMeta = primary.Labels, primary.LabelMeta
+		if slices.Contains(order, m.ModelName) {
+			return nil, fmt.Errorf("duplicate model name %q", m.ModelName)
+		}
+		reg.add(p, m)
+		order = append(order, m.ModelName)
+		log.Printf("Registered model %s.", m.ModelName)
+	}
+	return order, nil
+}
+
+func sameShape(want, got *Model) error {
+	if got.InputW != want.InputW || got.InputH != want.InputH || got.ClassCount != want.ClassCount {
+		return fmt.Errorf("model %s is %dx%d→%d, %s is %dx%d→%d",
+			got.ModelName, got.InputW, got.InputH, got.ClassCount,
+			want.ModelName, want.InputW, want.InputH, want.ClassCount)
+	}
+	return nil
+}
+
+// lookupModel resolves a request's model name; empty means the active model.
+// Names are only ever keys into the registry built at startup, never paths:
+// a request can pick among the loaded models but can't make the server
+// open a file, so "../x.json" is just another unknown name.
+func (s *Server) lookupModel(name string) (*Model, error) {
+	if name == "" {
+		return s.activeModel(), nil
+	}
+	m, ok, err := s.registered(name)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusNotFound,
+			fmt.Sprintf("unknown model %q; registered: %s", name, strings.Join(s.modelOrder, ", ")))
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	return m, nil
+}
+
+type memberPred struct {
+	Model    string  `json:"model"`
+	TopIndex int     `json:"top_index"`
+	TopScore float64 `json:"top_score"`
+}
+
+// forwardEnsemble runs img through every registered model and returns the
+// mean of their probability vectors plus each member's own top-1. It stops
+// at the first member ctx no longer allows.
+func (s *Server) forwardEnsemble(ctx context.Context, img [][]float64) ([]float64, []memberPred, bool, error) {
+	avg := make([]float64, s.ClassCount)
+	members := make([]memberPred, 0, len(s.modelOrder))
+	allGPU := true
+	for _, name := range s.modelOrder {
+		m, _, err := s.registered(name)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		out, usedGPU, err := s.forwardCtx(ctx, m, img)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		allGPU = allGPU && usedGPU
+		p := m.probs(out)
+		if len(p) != len(avg) {
+			return nil, nil, false, fmt.Errorf("model %s produced %d outputs, want %d", name, len(p), len(avg))
+		}
+		idx := argmax64(p)
+		members = append(members, memberPred{Model: name, TopIndex: idx, TopScore: p[idx]})
+		for i := range avg {
+			avg[i] += p[i] / float64(len(s.modelOrder))
+		}
+	}
+	return avg, members, allGPU, nil
+}
+
+// probs turns a raw output vector into probabilities, leaving it alone when
+// the model's outputs already are (OutputIsProb).
+func (m *Model) probs(out []float64) []float64 {
+	if m.OutputIsProb {
+		return out
+	}
+	return softmax64(out)
+}
+
+// scaleTemperature recalibrates out as softmax(logits / t). Outputs that
+// are already probabilities stand in for their logits as log p, which
+// softmax recovers up to a constant, so the two forms scale alike. t > 1
+// softens over-sharp confidences, t < 1 sharpens underconfident ones (as
+// from training with label smoothing); argmax never changes.
+func scaleTemperature(out []float64, isProb bool, t float64) []float64 {
+	logits := make([]float64, len(out))
+	for i, v := range out {
+		if isProb {
+			v = math.Log(max(v, math.SmallestNonzeroFloat64))
+		}
+		logits[i] = v / t
+	}
+	return softmax64(logits)
+}
+
+// probTolerance is how far from 1 an output vector may sum and still count
+// as a probability distribution (float32 forwards round).
+const probTolerance = 1e-3
+
+// looksLikeProbs reports whether every vector is non-negative and sums to
+// ~1, meaning the network normalizes its own output.
+func looksLikeProbs(outs ...[]float64) bool {
+	for _, out := range outs {
+		if len(out) < 2 {
+			return false
+		}
+		sum := 0.0
+		for _, v := range out {
+			if v < 0 {
+				return false
+			}
+			sum += v
+		}
+		if math.Abs(sum-1) > probTolerance {
+			return false
+		}
+	}
+	return len(outs) > 0
+}
+
+func softmax64(v []float64) []float64 {
+	out := make([]float64, len(v))
+	if len(v) == 0 {
+		return out
+	}
+	mx := v[0]
+	for _, x := range v[1:] {
+		mx = math.Max(mx, x)
+	}
+	sum := 0.0
+	for i, x := range v {
+		out[i] = math.Exp(x - mx)
+		sum += out[i]
+	}
+	for i := range out {
+		out[i] /= sum
+	}
+	return out
+}
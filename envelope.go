@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Optional response envelope (-envelope)
+// ─────────────────────────────────────────────────────────────
+
+// envelope wraps every buffered JSON response once the handler is done:
+// successes become {"ok":true,"data":…}; JSON error bodies (such as the
+// per-sample /infer-batch errors) gain "ok":false alongside their fields.
+// Streams (NDJSON, SSE), HTML, static files and /rpc pass through untouched.
+func envelope(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		return err // envelopeError formats it
+	}
+	res := c.Response()
+	if c.Path() == "/rpc" {
+		return nil // JSON-RPC has its own envelope
+	}
+	if res.IsBodyStream() || !bytes.HasPrefix(res.Header.ContentType(), []byte(fiber.MIMEApplicationJSON)) {
+		return nil
+	}
+	body := res.Body()
+	var out []byte
+	switch {
+	case res.StatusCode() < fiber.StatusBadRequest:
+		out = append(append([]byte(`{"ok":true,"data":`), body...), '}')
+	case bytes.Equal(body, []byte("{}")):
+		out = []byte(`{"ok":false}`)
+	case len(body) > 1 && body[0] == '{':
+		out = append([]byte(`{"ok":false,`), body[1:]...)
+	default:
+		return nil
+	}
+	res.SetBodyRaw(out)
+	return nil
+}
+
+// envelopeError is the app's error handler under -envelope, so errors
+// share the {"ok":false,"error":…} shape instead of plain text.
+func envelopeError(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		code = fe.Code
+	}
+	return c.Status(code).JSON(fiber.Map{"ok": false, "error": err.Error()})
+}
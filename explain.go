@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Occlusion attribution
+// ─────────────────────────────────────────────────────────────
+
+type explainReq struct {
+	inferReq
+	Patch  int     `json:"patch"`  // square patch side (default 4)
+	Stride int     `json:"stride"` // default = patch
+	Fill   float64 `json:"fill"`   // occluder value (default 0)
+}
+
+type explainResp struct {
+	TopIndex  int         `json:"top_index"`
+	TopScore  float64     `json:"top_score"`
+	Saliency  [][]float64 `json:"saliency"` // h×w, mean top-score drop when occluded
+	Patch     int         `json:"patch"`
+	Stride    int         `json:"stride"`
+	Forwards  int         `json:"forwards"`
+	Model     string      `json:"model"`
+	LatencyMs float64     `json:"latency_ms"`
+}
+
+// handleExplain slides a patch over the input and records how much the
+// top-class score drops with each region hidden. The number of forwards is
+// capped by -max-batch.
+func (s *Server) handleExplain(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	var req explainReq
+	if err := parseBody(c, &req, "'input' or 'image'"); err != nil {
+		return err
+	}
+	// One model for the preprocessing, the key check and the forwards.
+	m, err := s.lookupModel(req.Model)
+	if err != nil {
+		return err
+	}
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	trackModel(c, m.ModelName)
+	req.Model = m.ModelName
+	img, err := s.normalizeInput(req.inferReq)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if req.Patch == 0 {
+		req.Patch = 4
+	}
+	if req.Stride == 0 {
+		req.Stride = req.Patch
+	}
+	if req.Patch < 1 || req.Patch > min(s.InputW, s.InputH) || req.Stride < 1 {
+		return fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("patch must be 1..%d and stride ≥ 1", min(s.InputW, s.InputH)))
+	}
+	ys := occlusionOffsets(s.InputH, req.Patch, req.Stride)
+	xs := occlusionOffsets(s.InputW, req.Patch, req.Stride)
+	n := len(ys)*len(xs) + 1
+	if n > s.maxBatch {
+		return fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("patch/stride need %d forwards, over -max-batch %d", n, s.maxBatch))
+	}
+
+	prio, err := parsePriority(req.Priority)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	slot, _, err := s.acquireN(c.UserContext(), prio, nil, n)
+	if err != nil {
+		return acquireError(err)
+	}
+	defer s.release(slot)
+	s.stats.observeSlot(slot)
+	start := time.Now()
+
+	base, _, err := s.forwardCtx(c.UserContext(), m, img)
+	if err != nil {
+		return forwardError(err)
+	}
+	top := argmax64(base)
+
+	sum := makeImage(s.InputW, s.InputH, 0)
+	hits := makeImage(s.InputW, s.InputH, 0)
+	occluded := makeImage(s.InputW, s.InputH, 0)
+	for _, y0 := range ys {
+		for _, x0 := range xs {
+			for r := range img {
+				copy(occluded[r], img[r])
+			}
+			for r := y0; r < y0+req.Patch; r++ {
+				for x := x0; x < x0+req.Patch; x++ {
+					occluded[r][x] = req.Fill
+				}
+			}
+			out, _, err := s.forwardCtx(c.UserContext(), m, occluded)
+			if err != nil {
+				return forwardError(err)
+			}
+			drop := base[top] - out[top]
+			for r := y0; r < y0+req.Patch; r++ {
+				for x := x0; x < x0+req.Patch; x++ {
+					sum[r][x] += drop
+					hits[r][x]++
+				}
+			}
+		}
+	}
+	for r := range sum {
+		for x := range sum[r] {
+			if hits[r][x] > 0 {
+				sum[r][x] /= hits[r][x]
+			}
+		}
+	}
+
+	return c.JSON(explainResp{
+		TopIndex:  top,
+		TopScore:  base[top],
+		Saliency:  sum,
+		Patch:     req.Patch,
+		Stride:    req.Stride,
+		Forwards:  n,
+		Model:     m.ModelName,
+		LatencyMs: durMs(time.Since(start)),
+	})
+}
+
+// occlusionOffsets lists patch start positions along one axis, always
+// including a final patch flush with the edge.
+func occlusionOffsets(size, patch, stride int) []int {
+	var out []int
+	for o := 0; o+patch <= size; o += stride {
+		out = append(out, o)
+	}
+	if last := size - patch; len(out) == 0 || out[len(out)-1] != last {
+		out = append(out, last)
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Model files fetched over HTTP(S)
+// ─────────────────────────────────────────────────────────────
+
+const (
+	fetchTimeout  = 2 * time.Minute // per attempt
+	fetchMaxBytes = 2 << 30
+)
+
+// fetchAttempts and fetchBackoff are set by -model-fetch-attempts and
+// -model-fetch-backoff before any model is loaded.
+var (
+	fetchAttempts = 5
+	fetchBackoff  = time.Second
+)
+
+// isModelURL reports whether a -model/-models entry names an http(s) URL
+// rather than a file.
+func isModelURL(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// urlBase is the last path element of a model URL, its model name.
+func urlBase(u string) string {
+	if pu, err := url.Parse(u); err == nil && pu.Path != "" && pu.Path != "/" {
+		return path.Base(pu.Path)
+	}
+	return u
+}
+
+// fetchModel downloads a model, retrying network errors, 5xx and 429 with
+// exponential backoff (fetchBackoff, doubling) so a registry that is still
+// coming up, or a blip, doesn't fail startup. Other statuses fail at once.
+func fetchModel(u string) ([]byte, error) {
+	wait := fetchBackoff
+	for attempt := 1; ; attempt++ {
+		data, retry, err := fetchOnce(u)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Fetched %s on attempt %d.", u, attempt)
+			}
+			return data, nil
+		}
+		if !retry || attempt >= fetchAttempts {
+			return nil, fmt.Errorf("fetching %s: %w (attempt %d of %d)", u, err, attempt, fetchAttempts)
+		}
+		log.Printf("WARN: fetching %s (attempt %d of %d): %v; retrying in %s", u, attempt, fetchAttempts, err, wait)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// fetchOnce makes one attempt and says whether a failure is worth retrying.
+func fetchOnce(u string) ([]byte, bool, error) {
+	client := http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, fetchMaxBytes+1))
+	if err != nil {
+		return nil, true, err
+	}
+	if len(data) > fetchMaxBytes {
+		return nil, false, fmt.Errorf("model is larger than %d bytes", fetchMaxBytes)
+	}
+	return data, false, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openfluke/paragon_hosting_example/inferpb"
+)
+
+// ─────────────────────────────────────────────────────────────
+// gRPC transport (-grpc-addr)
+// ─────────────────────────────────────────────────────────────
+
+type grpcInference struct {
+	inferpb.UnimplementedInferenceServer
+	s *Server
+}
+
+// serveGRPC starts the gRPC server on addr in the background. The caller
+// stops it with GracefulStop at shutdown.
+func (s *Server) serveGRPC(addr string) (*grpc.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	gs := grpc.NewServer()
+	inferpb.RegisterInferenceServer(gs, &grpcInference{s: s})
+	go func() {
+		if err := gs.Serve(ln); err != nil {
+			log.Printf("WARN: gRPC server: %v", err)
+		}
+	}()
+	log.Printf("gRPC listening on %s", ln.Addr())
+	return gs, nil
+}
+
+// stopGRPC lets running RPCs finish until ctx expires, then cuts the rest
+// (an open InferStream would otherwise hold GracefulStop forever), and
+// reports whether it had to.
+func stopGRPC(ctx context.Context, gs *grpc.Server) (forced bool) {
+	if gs == nil {
+		return false
+	}
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return false
+	case <-ctx.Done():
+		gs.Stop()
+		return true
+	}
+}
+
+func (g *grpcInference) Infer(ctx context.Context, req *inferpb.InferRequest) (*inferpb.InferResponse, error) {
+	resp, err := g.s.inferRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp, nil
+}
+
+func (g *grpcInference) InferStream(stream inferpb.Inference_InferStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := g.s.inferRPC(stream.Context(), req)
+		if err != nil {
+			resp = &inferpb.InferResponse{TopIndex: -1, Error: err.Error()}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// inferRPC is /infer for one gRPC request: same validation, model lookup,
+// GPU slot and forward path. Errors are the *fiber.Error values the HTTP
+// handlers return, so grpcError can map their status.
+func (s *Server) inferRPC(ctx context.Context, req *inferpb.InferRequest) (*inferpb.InferResponse, error) {
+	ctx, cancel := s.withDeadline(ctx) // -timeout per RPC, or per InferStream message
+	defer cancel()
+	key, err := s.grpcKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	img, err := s.normalizeInput(inferReq{Input: req.GetInput(), Model: req.GetModel()})
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	m, err := s.lookupModel(req.GetModel())
+	if err != nil {
+		return nil, err
+	}
+	if err := key.allow(s.scopeName(m)); err != nil {
+		return nil, err
+	}
+	k := int(req.GetTopK())
+	if _, err := s.capTopK(&k, m.ClassCount); err != nil {
+		return nil, err
+	}
+	prio, err := parsePriority(req.GetPriority())
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	slot, qDelay, err := s.acquireIn(ctx, prio, s.lanes.infer)
+	if err != nil {
+		return nil, acquireError(err)
+	}
+	atomic.AddInt64(&s.inflight, 1)
+	defer func() {
+		s.releaseIn(slot, s.lanes.infer)
+		atomic.AddInt64(&s.inflight, -1)
+	}()
+
+	start := time.Now()
+	out, usedGPU, err := s.forwardCtx(ctx, m, img)
+	if err != nil {
+		return nil, forwardError(err)
+	}
+	lat := time.Since(start)
+	s.stats.observe(lat)
+	s.stats.observeSlot(slot)
+	s.sampler.maybe(m, img, out)
+	s.audit.record(m, img, out)
+
+	out, _, _ = m.present(out, nil) // -softmax-default; the proto has no field for it
+	idx := argmax64(out)
+	resp := &inferpb.InferResponse{
+		TopIndex:  int32(idx),
+		TopLabel:  m.label(idx),
+		TopScore:  out[idx],
+		UsedGpu:   usedGPU,
+		Model:     m.ModelName,
+		LatencyMs: durMs(lat),
+		QueuedMs:  durMs(qDelay),
+	}
+	if ks := m.topK(out, k); ks != nil {
+		for _, k := range ks {
+			resp.TopK = append(resp.TopK, &inferpb.ClassScore{Index: int32(k.Index), Label: k.Label, Score: k.Score})
+		}
+	} else {
+		resp.Probs = out
+	}
+	return resp, nil
+}
+
+// grpcError maps the HTTP status of err to the nearest gRPC code.
+func grpcError(err error) error {
+	var fe *fiber.Error
+	if !errors.As(err, &fe) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	code := codes.Internal
+	switch fe.Code {
+	case fiber.StatusBadRequest:
+		code = codes.InvalidArgument
+	case fiber.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case fiber.StatusForbidden:
+		code = codes.PermissionDenied
+	case fiber.StatusNotFound:
+		code = codes.NotFound
+	case fiber.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case fiber.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case fiber.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, fe.Message)
+}
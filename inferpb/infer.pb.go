@@ -0,0 +1,361 @@
+// gRPC transport for the hosting server (-grpc-addr). Mirrors POST /infer:
+// same model registry, GPU slots and gpuMu as the HTTP handlers.
+//
+// Regenerate after editing (protoc-gen-go, protoc-gen-go-grpc):
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	       --go-grpc_out=. --go-grpc_opt=paths=source_relative inferpb/infer.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: inferpb/infer.proto
+
+package inferpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type InferRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Input         []float64              `protobuf:"fixed64,1,rep,packed,name=input,proto3" json:"input,omitempty"`   // flattened h*w pixels in [0,1]
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`            // registered model name (default: active)
+	TopK          int32                  `protobuf:"varint,3,opt,name=top_k,json=topK,proto3" json:"top_k,omitempty"` // return the k best classes instead of probs
+	Priority      string                 `protobuf:"bytes,4,opt,name=priority,proto3" json:"priority,omitempty"`      // high | normal (default) | low
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InferRequest) Reset() {
+	*x = InferRequest{}
+	mi := &file_inferpb_infer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InferRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InferRequest) ProtoMessage() {}
+
+func (x *InferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inferpb_infer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InferRequest.ProtoReflect.Descriptor instead.
+func (*InferRequest) Descriptor() ([]byte, []int) {
+	return file_inferpb_infer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *InferRequest) GetInput() []float64 {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *InferRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *InferRequest) GetTopK() int32 {
+	if x != nil {
+		return x.TopK
+	}
+	return 0
+}
+
+func (x *InferRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+type ClassScore struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Label         string                 `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+	Score         float64                `protobuf:"fixed64,3,opt,name=score,proto3" json:"score,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClassScore) Reset() {
+	*x = ClassScore{}
+	mi := &file_inferpb_infer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassScore) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassScore) ProtoMessage() {}
+
+func (x *ClassScore) ProtoReflect() protoreflect.Message {
+	mi := &file_inferpb_infer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassScore.ProtoReflect.Descriptor instead.
+func (*ClassScore) Descriptor() ([]byte, []int) {
+	return file_inferpb_infer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ClassScore) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ClassScore) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *ClassScore) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type InferResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TopIndex      int32                  `protobuf:"varint,1,opt,name=top_index,json=topIndex,proto3" json:"top_index,omitempty"`
+	TopLabel      string                 `protobuf:"bytes,2,opt,name=top_label,json=topLabel,proto3" json:"top_label,omitempty"`
+	TopScore      float64                `protobuf:"fixed64,3,opt,name=top_score,json=topScore,proto3" json:"top_score,omitempty"`
+	Probs         []float64              `protobuf:"fixed64,4,rep,packed,name=probs,proto3" json:"probs,omitempty"`
+	TopK          []*ClassScore          `protobuf:"bytes,5,rep,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	UsedGpu       bool                   `protobuf:"varint,6,opt,name=used_gpu,json=usedGpu,proto3" json:"used_gpu,omitempty"`
+	Model         string                 `protobuf:"bytes,7,opt,name=model,proto3" json:"model,omitempty"`
+	LatencyMs     float64                `protobuf:"fixed64,8,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	QueuedMs      float64                `protobuf:"fixed64,9,opt,name=queued_ms,json=queuedMs,proto3" json:"queued_ms,omitempty"`
+	Error         string                 `protobuf:"bytes,10,opt,name=error,proto3" json:"error,omitempty"` // InferStream only: why this sample failed
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InferResponse) Reset() {
+	*x = InferResponse{}
+	mi := &file_inferpb_infer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InferResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InferResponse) ProtoMessage() {}
+
+func (x *InferResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inferpb_infer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InferResponse.ProtoReflect.Descriptor instead.
+func (*InferResponse) Descriptor() ([]byte, []int) {
+	return file_inferpb_infer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *InferResponse) GetTopIndex() int32 {
+	if x != nil {
+		return x.TopIndex
+	}
+	return 0
+}
+
+func (x *InferResponse) GetTopLabel() string {
+	if x != nil {
+		return x.TopLabel
+	}
+	return ""
+}
+
+func (x *InferResponse) GetTopScore() float64 {
+	if x != nil {
+		return x.TopScore
+	}
+	return 0
+}
+
+func (x *InferResponse) GetProbs() []float64 {
+	if x != nil {
+		return x.Probs
+	}
+	return nil
+}
+
+func (x *InferResponse) GetTopK() []*ClassScore {
+	if x != nil {
+		return x.TopK
+	}
+	return nil
+}
+
+func (x *InferResponse) GetUsedGpu() bool {
+	if x != nil {
+		return x.UsedGpu
+	}
+	return false
+}
+
+func (x *InferResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *InferResponse) GetLatencyMs() float64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *InferResponse) GetQueuedMs() float64 {
+	if x != nil {
+		return x.QueuedMs
+	}
+	return 0
+}
+
+func (x *InferResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_inferpb_infer_proto protoreflect.FileDescriptor
+
+const file_inferpb_infer_proto_rawDesc = "" +
+	"\n" +
+	"\x13inferpb/infer.proto\x12\x12paragon.hosting.v1\"k\n" +
+	"\fInferRequest\x12\x14\n" +
+	"\x05input\x18\x01 \x03(\x01R\x05input\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12\x13\n" +
+	"\x05top_k\x18\x03 \x01(\x05R\x04topK\x12\x1a\n" +
+	"\bpriority\x18\x04 \x01(\tR\bpriority\"N\n" +
+	"\n" +
+	"ClassScore\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x14\n" +
+	"\x05label\x18\x02 \x01(\tR\x05label\x12\x14\n" +
+	"\x05score\x18\x03 \x01(\x01R\x05score\"\xb4\x02\n" +
+	"\rInferResponse\x12\x1b\n" +
+	"\ttop_index\x18\x01 \x01(\x05R\btopIndex\x12\x1b\n" +
+	"\ttop_label\x18\x02 \x01(\tR\btopLabel\x12\x1b\n" +
+	"\ttop_score\x18\x03 \x01(\x01R\btopScore\x12\x14\n" +
+	"\x05probs\x18\x04 \x03(\x01R\x05probs\x123\n" +
+	"\x05top_k\x18\x05 \x03(\v2\x1e.paragon.hosting.v1.ClassScoreR\x04topK\x12\x19\n" +
+	"\bused_gpu\x18\x06 \x01(\bR\ausedGpu\x12\x14\n" +
+	"\x05model\x18\a \x01(\tR\x05model\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\b \x01(\x01R\tlatencyMs\x12\x1b\n" +
+	"\tqueued_ms\x18\t \x01(\x01R\bqueuedMs\x12\x14\n" +
+	"\x05error\x18\n" +
+	" \x01(\tR\x05error2\xb1\x01\n" +
+	"\tInference\x12L\n" +
+	"\x05Infer\x12 .paragon.hosting.v1.InferRequest\x1a!.paragon.hosting.v1.InferResponse\x12V\n" +
+	"\vInferStream\x12 .paragon.hosting.v1.InferRequest\x1a!.paragon.hosting.v1.InferResponse(\x010\x01B6Z4github.com/openfluke/paragon_hosting_example/inferpbb\x06proto3"
+
+var (
+	file_inferpb_infer_proto_rawDescOnce sync.Once
+	file_inferpb_infer_proto_rawDescData []byte
+)
+
+func file_inferpb_infer_proto_rawDescGZIP() []byte {
+	file_inferpb_infer_proto_rawDescOnce.Do(func() {
+		file_inferpb_infer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_inferpb_infer_proto_rawDesc), len(file_inferpb_infer_proto_rawDesc)))
+	})
+	return file_inferpb_infer_proto_rawDescData
+}
+
+var file_inferpb_infer_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_inferpb_infer_proto_goTypes = []any{
+	(*InferRequest)(nil),  // 0: paragon.hosting.v1.InferRequest
+	(*ClassScore)(nil),    // 1: paragon.hosting.v1.ClassScore
+	(*InferResponse)(nil), // 2: paragon.hosting.v1.InferResponse
+}
+var file_inferpb_infer_proto_depIdxs = []int32{
+	1, // 0: paragon.hosting.v1.InferResponse.top_k:type_name -> paragon.hosting.v1.ClassScore
+	0, // 1: paragon.hosting.v1.Inference.Infer:input_type -> paragon.hosting.v1.InferRequest
+	0, // 2: paragon.hosting.v1.Inference.InferStream:input_type -> paragon.hosting.v1.InferRequest
+	2, // 3: paragon.hosting.v1.Inference.Infer:output_type -> paragon.hosting.v1.InferResponse
+	2, // 4: paragon.hosting.v1.Inference.InferStream:output_type -> paragon.hosting.v1.InferResponse
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_inferpb_infer_proto_init() }
+func file_inferpb_infer_proto_init() {
+	if File_inferpb_infer_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_inferpb_infer_proto_rawDesc), len(file_inferpb_infer_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_inferpb_infer_proto_goTypes,
+		DependencyIndexes: file_inferpb_infer_proto_depIdxs,
+		MessageInfos:      file_inferpb_infer_proto_msgTypes,
+	}.Build()
+	File_inferpb_infer_proto = out.File
+	file_inferpb_infer_proto_goTypes = nil
+	file_inferpb_infer_proto_depIdxs = nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// In-flight request tracking & cancellation
+// ─────────────────────────────────────────────────────────────
+
+var errCanceled = errors.New("request canceled by an operator")
+
+// tracked is one running inference request.
+type tracked struct {
+	ID      string
+	Method  string
+	Path    string
+	Started time.Time
+
+	model    atomic.Pointer[string] // set by handlers that pick a model
+	canceled atomic.Bool
+	job      context.Context // the request's context without its deadline (jobContext)
+	cancel   context.CancelFunc
+	detached bool // a body stream owns the entry now (see detach)
+}
+
+// requests is the registry behind /admin/inflight.
+type requests struct {
+	next atomic.Uint64
+	m    sync.Map // id → *tracked
+}
+
+// track registers the request for /admin/inflight and gives it a
+// cancellable context (c.UserContext) that expires -timeout after arrival.
+// The ID is the client's X-Request-ID when it sends one that isn't already
+// running, otherwise a sequence number, and is echoed back in the
+// X-Request-ID response header next to the server's timeouts
+// (timeoutHeaders).
+func (s *Server) track(c *fiber.Ctx) error {
+	job, cancelJob := context.WithCancel(c.UserContext())
+	ctx, cancel := s.withDeadline(job)
+	t := &tracked{
+		Method:  c.Method(),
+		Path:    strings.Clone(c.Path()),
+		Started: time.Now(),
+		job:     job,
+		cancel: func() {
+			cancel()
+			cancelJob()
+		},
+	}
+	name := s.activeModel().ModelName
+	t.model.Store(&name)
+
+	id := strings.Clone(c.Get(fiber.HeaderXRequestID))
+	if id == "" {
+		id = strconv.FormatUint(s.reqs.next.Add(1), 10)
+	}
+	t.ID = id
+	if _, dup := s.reqs.m.LoadOrStore(id, t); dup {
+		t.ID = id + "-" + strconv.FormatUint(s.reqs.next.Add(1), 10)
+		s.reqs.m.Store(t.ID, t)
+	}
+	defer func() {
+		if !t.detached {
+			s.untrack(t)
+		}
+	}()
+
+	c.Set(fiber.HeaderXRequestID, t.ID)
+	s.timeoutHeaders(c)
+	c.SetUserContext(ctx)
+	c.Locals("tracked", t)
+	atomic.AddInt64(&s.stats.requests, 1)
+	err := c.Next()
+	if err != nil {
+		atomic.AddInt64(&s.stats.errors, 1)
+		s.setRetryAfter(c, err)
+	}
+	return err
+}
+
+func (s *Server) untrack(t *tracked) {
+	s.reqs.m.Delete(t.ID)
+	t.cancel()
+}
+
+// detach keeps the request tracked and its context live after the handler
+// returns, for body stream writers that run later. The returned func ends
+// tracking and must be called when the stream is done.
+func (s *Server) detach(c *fiber.Ctx) (context.Context, func()) {
+	t, ok := c.Locals("tracked").(*tracked)
+	if !ok {
+		return context.Background(), func() {}
+	}
+	t.detached = true
+	return c.UserContext(), func() { s.untrack(t) }
+}
+
+// jobContext is the request's context without its -timeout deadline, still
+// ended by /admin/inflight, for handlers that apply -timeout to each unit
+// of work instead (each /score line, each paced /blast submission).
+func jobContext(c *fiber.Ctx) context.Context {
+	if t, ok := c.Locals("tracked").(*tracked); ok {
+		return t.job
+	}
+	return c.UserContext()
+}
+
+// trackModel records which model a tracked request runs on.
+func trackModel(c *fiber.Ctx, name string) {
+	if t, ok := c.Locals("tracked").(*tracked); ok {
+		t.model.Store(&name)
+	}
+}
+
+// forwardCtx is forward for a request with a context: it doesn't start once
+// ctx is canceled or past its deadline, so a request stops at its next
+// forward. A forward already on the GPU runs to completion.
+func (s *Server) forwardCtx(ctx context.Context, m *Model, img [][]float64) ([]float64, bool, error) {
+	if ctx.Err() != nil {
+		return nil, false, ctxErr(ctx)
+	}
+	return s.forward(m, img)
+}
+
+type inflightItem struct {
+	ID       string  `json:"id"`
+	Method   string  `json:"method"`
+	Path     string  `json:"path"`
+	Model    string  `json:"model"`
+	Started  apiTime `json:"started"`
+	AgeMs    float64 `json:"age_ms"`
+	Canceled bool    `json:"canceled"`
+}
+
+func (s *Server) handleInflight(c *fiber.Ctx) error {
+	items := []inflightItem{}
+	s.reqs.m.Range(func(_, v any) bool {
+		t := v.(*tracked)
+		items = append(items, inflightItem{
+			ID: t.ID, Method: t.Method, Path: t.Path, Model: *t.model.Load(),
+			Started: apiTime(t.Started), AgeMs: durMs(time.Since(t.Started)), Canceled: t.canceled.Load(),
+		})
+		return true
+	})
+	sort.Slice(items, func(a, b int) bool { return time.Time(items[a].Started).Before(time.Time(items[b].Started)) })
+	return c.JSON(fiber.Map{"count": len(items), "requests": items})
+}
+
+// handleCancelInflight cancels one tracked request. It stops at its next
+// queue wait or between forwards and answers 503.
+func (s *Server) handleCancelInflight(c *fiber.Ctx) error {
+	id := c.Params("id")
+	v, ok := s.reqs.m.Load(id)
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "no in-flight request "+id)
+	}
+	t := v.(*tracked)
+	t.canceled.Store(true)
+	t.cancel()
+	return c.JSON(fiber.Map{"canceled": true, "id": id, "path": t.Path})
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Per-value input transform (-input-expr)
+// ─────────────────────────────────────────────────────────────
+
+// inputExpr is set by -input-expr before the server starts; nil applies no
+// transform.
+var inputExpr *valueExpr
+
+// valueExpr is a compiled expression in one variable, v.
+type valueExpr struct {
+	src  string
+	eval func(v float64) float64
+}
+
+// String is the expression as given, "" for none.
+func (e *valueExpr) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.src
+}
+
+// exprFuncs are the one-argument functions, exprFuncs2 the two-argument ones.
+var (
+	exprFuncs = map[string]func(float64) float64{
+		"abs": math.Abs, "exp": math.Exp, "log": math.Log, "log2": math.Log2,
+		"log10": math.Log10, "sqrt": math.Sqrt, "tanh": math.Tanh,
+	}
+	exprFuncs2 = map[string]func(float64, float64) float64{
+		"min": math.Min, "max": math.Max, "pow": math.Pow,
+	}
+)
+
+var exprConsts = map[string]float64{"pi": math.Pi, "e": math.E}
+
+// exprFuncNames lists the functions for error messages.
+func exprFuncNames() string {
+	return "abs, exp, log, log2, log10, sqrt, tanh, min, max, pow"
+}
+
+// parseValueExpr compiles src, e.g. "v*2-1" or "log(v+1)": numbers, v, pi,
+// e, + - * / ^ and parentheses, and the functions of exprFuncNames. A
+// division by a constant zero is rejected here; checkPre catches the rest.
+func parseValueExpr(src string) (*valueExpr, error) {
+	p := &exprParser{src: src}
+	p.next()
+	n, err := p.sum()
+	if err == nil && p.tok != "" {
+		err = p.errorf("unexpected %q", p.tok)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("-input-expr %q: %w", src, err)
+	}
+	return &valueExpr{src: src, eval: n.eval}, nil
+}
+
+// checkPre fails when e isn't a finite number somewhere in the range of
+// values p hands it: clamped pixels, inverted and standardized per channel.
+func (e *valueExpr) checkPre(p *preprocess) error {
+	for ch := range p.Channels {
+		lo, hi := 0.0, 1.0
+		if len(p.Mean) > 0 {
+			lo, hi = lo-p.Mean[ch], hi-p.Mean[ch]
+		}
+		if len(p.Std) > 0 {
+			lo, hi = lo/p.Std[ch], hi/p.Std[ch]
+		}
+		if err := e.checkDefined(min(lo, hi), max(lo, hi)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDefined evaluates e across [lo, hi] and fails on the first result
+// that isn't a finite number.
+func (e *valueExpr) checkDefined(lo, hi float64) error {
+	const steps = 1000
+	for i := 0; i <= steps; i++ {
+		v := lo + (hi-lo)*float64(i)/steps
+		if r := e.eval(v); math.IsNaN(r) || math.IsInf(r, 0) {
+			return fmt.Errorf("-input-expr %q is %v at v=%g; inputs span [%g, %g]", e.src, r, v, lo, hi)
+		}
+	}
+	return nil
+}
+
+// exprNode is a parsed subexpression: its value as a function of v, and
+// whether that value is constant (for the division check).
+type exprNode struct {
+	eval  func(v float64) float64
+	konst bool
+}
+
+func constNode(x float64) exprNode {
+	return exprNode{eval: func(float64) float64 { return x }, konst: true}
+}
+
+type exprParser struct {
+	src string
+	pos int    // offset after tok
+	tok string // current token; "" at the end
+}
+
+func (p *exprParser) errorf(format string, a ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.pos-len(p.tok), fmt.Sprintf(format, a...))
+}
+
+// next scans the following token: a number, a name, or one operator rune.
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	start := p.pos
+	switch {
+	case p.pos == len(p.src):
+	case isExprDigit(rune(p.src[p.pos])):
+		for p.pos < len(p.src) && (isExprDigit(rune(p.src[p.pos])) ||
+			// exponent sign, as in 1e-3
+			(p.src[p.pos] == '-' || p.src[p.pos] == '+') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E') ||
+			p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			p.pos++
+		}
+	case unicode.IsLetter(rune(p.src[p.pos])):
+		for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func isExprDigit(r rune) bool { return unicode.IsDigit(r) || r == '.' }
+
+// sum = product { ("+" | "-") product }
+func (p *exprParser) sum() (exprNode, error) {
+	l, err := p.product()
+	for err == nil && (p.tok == "+" || p.tok == "-") {
+		op := p.tok
+		p.next()
+		var r exprNode
+		if r, err = p.product(); err != nil {
+			break
+		}
+		lf, rf := l.eval, r.eval
+		if op == "+" {
+			l = exprNode{eval: func(v float64) float64 { return lf(v) + rf(v) }, konst: l.konst && r.konst}
+		} else {
+			l = exprNode{eval: func(v float64) float64 { return lf(v) - rf(v) }, konst: l.konst && r.konst}
+		}
+	}
+	return l, err
+}
+
+// product = unary { ("*" | "/") unary }
+func (p *exprParser) product() (exprNode, error) {
+	l, err := p.unary()
+	for err == nil && (p.tok == "*" || p.tok == "/") {
+		op := p.tok
+		p.next()
+		var r exprNode
+		if r, err = p.unary(); err != nil {
+			break
+		}
+		lf, rf := l.eval, r.eval
+		if op == "*" {
+			l = exprNode{eval: func(v float64) float64 { return lf(v) * rf(v) }, konst: l.konst && r.konst}
+			continue
+		}
+		if r.konst && rf(0) == 0 {
+			return l, p.errorf("division by zero")
+		}
+		l = exprNode{eval: func(v float64) float64 { return lf(v) / rf(v) }, konst: l.konst && r.konst}
+	}
+	return l, err
+}
+
+// unary = "-" unary | power
+func (p *exprParser) unary() (exprNode, error) {
+	if p.tok == "-" || p.tok == "+" {
+		neg := p.tok == "-"
+		p.next()
+		n, err := p.unary()
+		if err != nil || !neg {
+			return n, err
+		}
+		f := n.eval
+		return exprNode{eval: func(v float64) float64 { return -f(v) }, konst: n.konst}, nil
+	}
+	return p.power()
+}
+
+// power = primary [ "^" unary ]
+func (p *exprParser) power() (exprNode, error) {
+	b, err := p.primary()
+	if err != nil || p.tok != "^" {
+		return b, err
+	}
+	p.next()
+	x, err := p.unary()
+	if err != nil {
+		return b, err
+	}
+	bf, xf := b.eval, x.eval
+	return exprNode{eval: func(v float64) float64 { return math.Pow(bf(v), xf(v)) }, konst: b.konst && x.konst}, nil
+}
+
+// primary = number | "v" | constant | name "(" args ")" | "(" sum ")"
+func (p *exprParser) primary() (exprNode, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return exprNode{}, p.errorf("unexpected end of expression")
+	case tok == "(":
+		p.next()
+		n, err := p.sum()
+		if err != nil {
+			return n, err
+		}
+		if p.tok != ")" {
+			return n, p.errorf("missing )")
+		}
+		p.next()
+		return n, nil
+	case isExprDigit(rune(tok[0])):
+		x, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return exprNode{}, p.errorf("bad number %q", tok)
+		}
+		p.next()
+		return constNode(x), nil
+	case tok == "v":
+		p.next()
+		return exprNode{eval: func(v float64) float64 { return v }}, nil
+	case unicode.IsLetter(rune(tok[0])):
+		name := strings.ToLower(tok)
+		if x, ok := exprConsts[name]; ok {
+			p.next()
+			return constNode(x), nil
+		}
+		f1, ok1 := exprFuncs[name]
+		f2, ok2 := exprFuncs2[name]
+		if !ok1 && !ok2 {
+			return exprNode{}, p.errorf("unknown name %q (functions: %s; the variable is v)", tok, exprFuncNames())
+		}
+		p.next()
+		if p.tok != "(" {
+			return exprNode{}, p.errorf("%s needs (", name)
+		}
+		var args []exprNode
+		for {
+			p.next()
+			a, err := p.sum()
+			if err != nil {
+				return a, err
+			}
+			args = append(args, a)
+			if p.tok != "," {
+				break
+			}
+		}
+		if p.tok != ")" {
+			return exprNode{}, p.errorf("missing ) after %s arguments", name)
+		}
+		want := 2
+		if ok1 {
+			want = 1
+		}
+		if len(args) != want {
+			return exprNode{}, p.errorf("%s takes %d argument(s), got %d", name, want, len(args))
+		}
+		p.next()
+		a := args[0].eval
+		if ok1 {
+			return exprNode{eval: func(v float64) float64 { return f1(a(v)) }, konst: args[0].konst}, nil
+		}
+		b := args[1].eval
+		return exprNode{eval: func(v float64) float64 { return f2(a(v), b(v)) }, konst: args[0].konst && args[1].konst}, nil
+	}
+	return exprNode{}, p.errorf("unexpected %q", tok)
+}
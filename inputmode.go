@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Input mode: clamp (default) or strict
+// ─────────────────────────────────────────────────────────────
+
+const (
+	inputClamp  = "clamp"  // out-of-range pixels are clamped to [0,1]
+	inputStrict = "strict" // out-of-range or NaN pixels are a 400
+)
+
+func (s *Server) inputMode() string {
+	if s.strict.Load() {
+		return inputStrict
+	}
+	return inputClamp
+}
+
+// setInputMode switches the mode and returns the previous one.
+func (s *Server) setInputMode(mode string) (string, error) {
+	switch mode {
+	case inputClamp, inputStrict:
+	default:
+		return "", fmt.Errorf("input mode must be %q or %q", inputClamp, inputStrict)
+	}
+	if s.strict.Swap(mode == inputStrict) {
+		return inputStrict, nil
+	}
+	return inputClamp, nil
+}
+
+// checkRange rejects raw pixels outside [0,1] when strict mode is on.
+func (s *Server) checkRange(img [][]float64) error {
+	if !s.strict.Load() {
+		return nil
+	}
+	for r, row := range img {
+		for c, v := range row {
+			if math.IsNaN(v) || v < 0 || v > 1 {
+				return fmt.Errorf("pixel [%d][%d] = %v is outside [0,1] (input mode strict)", r, c, v)
+			}
+		}
+	}
+	return nil
+}
+
+type inputModeReq struct {
+	Mode string `json:"mode"`
+}
+
+// handleInputMode serves GET and PUT /admin/input-mode. PUT answers with the
+// previous mode so a debugging session can put it back afterwards.
+func (s *Server) handleInputMode(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodGet {
+		return c.JSON(fiber.Map{"mode": s.inputMode()})
+	}
+	var req inputModeReq
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	prev, err := s.setInputMode(req.Mode)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if prev != req.Mode {
+		log.Printf("Input mode %s → %s.", prev, req.Mode)
+	}
+	return c.JSON(fiber.Map{"mode": req.Mode, "previous": prev})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Input interpolation
+// ─────────────────────────────────────────────────────────────
+
+type interpolateReq struct {
+	A     inferReq `json:"a"`
+	B     inferReq `json:"b"`
+	Steps int      `json:"steps"` // points from a to b inclusive (default 11)
+}
+
+type interpStep struct {
+	T        float64 `json:"t"`
+	TopIndex int     `json:"top_index"`
+	TopLabel string  `json:"top_label,omitempty"`
+	TopScore float64 `json:"top_score"`
+}
+
+type interpFlip struct {
+	FromT float64 `json:"from_t"`
+	ToT   float64 `json:"to_t"`
+	From  int     `json:"from"`
+	To    int     `json:"to"`
+}
+
+// handleInterpolate walks the straight line between two normalized inputs
+// and reports the top class at each point and where it changes.
+func (s *Server) handleInterpolate(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	var req interpolateReq
+	if err := parseBody(c, &req, "'a' and 'b'"); err != nil {
+		return err
+	}
+	// One model for the preprocessing, the key check and the forwards.
+	if req.B.Model != "" && req.B.Model != req.A.Model {
+		return fiber.NewError(fiber.StatusBadRequest, "a and b must name the same model; b may leave it out")
+	}
+	m, err := s.lookupModel(req.A.Model)
+	if err != nil {
+		return err
+	}
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	trackModel(c, m.ModelName)
+	req.A.Model, req.B.Model = m.ModelName, m.ModelName
+	a, err := s.normalizeInput(req.A)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "a: "+err.Error())
+	}
+	b, err := s.normalizeInput(req.B)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "b: "+err.Error())
+	}
+	if req.Steps == 0 {
+		req.Steps = 11
+	}
+	if req.Steps < 2 || req.Steps > s.maxBatch {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("steps must be 2..%d", s.maxBatch))
+	}
+
+	prio, err := parsePriority(req.A.Priority)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "a: "+err.Error())
+	}
+
+	slot, _, err := s.acquireN(c.UserContext(), prio, nil, req.Steps)
+	if err != nil {
+		return acquireError(err)
+	}
+	defer s.release(slot)
+	s.stats.observeSlot(slot)
+	start := time.Now()
+
+	steps := make([]interpStep, req.Steps)
+	var flips []interpFlip
+	mix := makeImage(s.InputW, s.InputH, 0)
+	for i := range steps {
+		t := float64(i) / float64(req.Steps-1)
+		for r := range mix {
+			for x := range mix[r] {
+				mix[r][x] = (1-t)*a[r][x] + t*b[r][x]
+			}
+		}
+		out, _, err := s.forwardCtx(c.UserContext(), m, mix)
+		if err != nil {
+			return forwardError(err)
+		}
+		idx := argmax64(out)
+		steps[i] = interpStep{T: t, TopIndex: idx, TopLabel: m.label(idx), TopScore: out[idx]}
+		if i > 0 && steps[i-1].TopIndex != idx {
+			flips = append(flips, interpFlip{FromT: steps[i-1].T, ToT: t, From: steps[i-1].TopIndex, To: idx})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"steps":      steps,
+		"flips":      flips,
+		"model":      m.ModelName,
+		"latency_ms": durMs(time.Since(start)),
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Input arrays sent as JSON strings
+// ─────────────────────────────────────────────────────────────
+
+// flatInput and imageRows are the "input" and "image" request fields. Some
+// clients JSON-encode the array a second time and send "[0.1,0.2,...]" as
+// a string; both types accept that and decode the string's contents, so
+// the mistake costs nothing, and say so plainly when the string isn't an
+// array either.
+type (
+	flatInput []float64
+	imageRows [][]float64
+)
+
+func (v *flatInput) UnmarshalJSON(b []byte) error {
+	return unmarshalArray(b, (*[]float64)(v), "input", "a flat array of numbers")
+}
+
+func (v *imageRows) UnmarshalJSON(b []byte) error {
+	return unmarshalArray(b, (*[][]float64)(v), "image", "an array of rows of numbers")
+}
+
+// unmarshalArray decodes b into dst, first unquoting it when it is a JSON
+// string.
+func unmarshalArray(b []byte, dst any, field, shape string) error {
+	if len(b) == 0 || b[0] != '"' {
+		if err := json.Unmarshal(b, dst); err != nil {
+			return fmt.Errorf("%s must be %s", field, shape)
+		}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bytes.TrimSpace([]byte(s)), dst); err != nil {
+		return fmt.Errorf("%s was sent as a string that doesn't hold %s; send the array itself, not a JSON-encoded copy", field, shape)
+	}
+	return nil
+}
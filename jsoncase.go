@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Response key naming (-json-case)
+// ─────────────────────────────────────────────────────────────
+
+const (
+	caseSnake = "snake"
+	caseCamel = "camel"
+)
+
+// jsonCase is the default response key style, set by -json-case before
+// the server starts.
+var jsonCase = caseSnake
+
+// camelCase reports whether c's response keys should be camelCase: the
+// X-JSON-Case header or json_case query parameter (snake|camel) pick per
+// request, -json-case otherwise.
+func camelCase(c *fiber.Ctx) (bool, error) {
+	want := c.Get("X-JSON-Case", c.Query("json_case"))
+	switch want {
+	case "":
+		return jsonCase == caseCamel, nil
+	case caseSnake, caseCamel:
+		return want == caseCamel, nil
+	}
+	return false, fiber.NewError(fiber.StatusBadRequest, "X-JSON-Case must be snake or camel")
+}
+
+// keyCase rewrites buffered JSON responses to camelCase keys when asked.
+// Every response struct is tagged snake_case, so this is the only place
+// the other style exists. Streams write their lines through encodeLine.
+func keyCase(c *fiber.Ctx) error {
+	camel, err := camelCase(c)
+	if err != nil {
+		return err
+	}
+	c.Vary("X-JSON-Case")
+	c.Locals("camel", camel)
+	if err := c.Next(); err != nil || !camel {
+		return err
+	}
+	res := c.Response()
+	if res.IsBodyStream() || !bytes.HasPrefix(res.Header.ContentType(), []byte(fiber.MIMEApplicationJSON)) {
+		return nil
+	}
+	res.SetBodyRaw(camelKeys(res.Body()))
+	return nil
+}
+
+// wantsCamel is camelCase as resolved by keyCase, for stream writers that
+// run after the handler returns.
+func wantsCamel(c *fiber.Ctx) bool {
+	camel, _ := c.Locals("camel").(bool)
+	return camel
+}
+
+// encodeLine writes v as one JSON line, camelCased when camel is set.
+func encodeLine(w *bufio.Writer, v any, camel bool) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if camel {
+		b = camelKeys(b)
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+// dataKeyed lists the response fields holding maps keyed by data (model
+// names, lanes, priorities) rather than by field names. camelKeys leaves
+// their keys alone, so a client can send them back as they came. A new
+// map-typed response field whose keys are data belongs here.
+var dataKeyed = map[string]bool{
+	"by_model":             true, // /admin/keys
+	"model_preprocess":     true, // /config
+	"queued_by_priority":   true, // /stats
+	"inflight_by_endpoint": true, // /stats
+}
+
+// camelKeys converts the snake_case object keys of a JSON document to
+// camelCase without decoding it. A string followed by ':' is a key; values,
+// including strings, pass through byte for byte. The keys of a dataKeyed
+// field's object (or of the objects in its array) are data and pass
+// through too; the objects nested in its values are converted again.
+func camelKeys(b []byte) []byte {
+	type scope struct{ data, array bool }
+	var (
+		out      = make([]byte, 0, len(b))
+		stack    []scope
+		dataNext bool // the value about to start is a dataKeyed field's
+	)
+	inData := func() bool { return len(stack) > 0 && stack[len(stack)-1].data }
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '{', '[':
+			data := dataNext || len(stack) > 0 && stack[len(stack)-1].array && inData()
+			stack = append(stack, scope{data: data, array: b[i] == '['})
+			dataNext = false
+			out = append(out, b[i])
+			continue
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			out = append(out, b[i])
+			continue
+		case '"':
+		default:
+			if c := b[i]; c != ' ' && c != '\n' && c != '\t' && c != '\r' && c != ':' {
+				dataNext = false // a scalar value
+			}
+			out = append(out, b[i])
+			continue
+		}
+		end := i + 1
+		escaped := false
+		for ; end < len(b) && b[end] != '"'; end++ {
+			if b[end] == '\\' {
+				escaped = true
+				end++
+			}
+		}
+		str := b[i : end+1]
+		j := end + 1
+		for j < len(b) && (b[j] == ' ' || b[j] == '\n' || b[j] == '\t' || b[j] == '\r') {
+			j++
+		}
+		isKey := j < len(b) && b[j] == ':'
+		switch {
+		case isKey && !inData() && !escaped && bytes.IndexByte(str, '_') > 0:
+			out = appendCamel(out, str)
+		default:
+			out = append(out, str...)
+		}
+		dataNext = isKey && !inData() && !escaped && dataKeyed[string(str[1:len(str)-1])]
+		i = end
+	}
+	return out
+}
+
+// appendCamel appends the quoted key q with each "_x" after the first
+// character turned into "X".
+func appendCamel(out, q []byte) []byte {
+	up := false
+	for k, ch := range q {
+		switch {
+		case ch == '_' && k > 1 && k < len(q)-2:
+			up = true
+		case up && 'a' <= ch && ch <= 'z':
+			out = append(out, ch-'a'+'A')
+			up = false
+		default:
+			out = append(out, ch)
+			up = false
+		}
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc/metadata"
+)
+
+// ─────────────────────────────────────────────────────────────
+// API keys scoped to models (-api-keys)
+// ─────────────────────────────────────────────────────────────
+
+const apiKeyHeader = "X-API-Key"
+
+// apiKey is one entry of the -api-keys file, with its usage since startup.
+type apiKey struct {
+	Name   string   `json:"name"`   // shown in /admin/keys and errors; the key itself never is
+	Key    string   `json:"key"`    // sent by clients in X-API-Key
+	Models []string `json:"models"` // registry names it may use; "*" for all of them
+
+	all     bool
+	allowed map[string]bool
+
+	requests  atomic.Int64
+	forbidden atomic.Int64
+
+	mu       sync.Mutex
+	byModel  map[string]int64
+	lastUsed time.Time
+}
+
+// loadAPIKeys reads an -api-keys file, a JSON array of
+// {"name","key","models"}, and checks every model against the registry.
+func loadAPIKeys(path string, registered []string) ([]*apiKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var keys []*apiKey
+	if err := dec.Decode(&keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no keys", path)
+	}
+	names, secrets := map[string]bool{}, map[string]bool{}
+	for i, k := range keys {
+		switch {
+		case k == nil || k.Name == "" || k.Key == "":
+			return nil, fmt.Errorf("%s: entry %d needs a name and a key", path, i)
+		case names[k.Name]:
+			return nil, fmt.Errorf("%s: key name %q is used twice", path, k.Name)
+		case secrets[k.Key]:
+			return nil, fmt.Errorf("%s: key %q has the same secret as an earlier entry", path, k.Name)
+		case len(k.Models) == 0:
+			return nil, fmt.Errorf(`%s: key %q lists no models (use "*" for all)`, path, k.Name)
+		}
+		names[k.Name], secrets[k.Key] = true, true
+		k.allowed, k.byModel = map[string]bool{}, map[string]int64{}
+		for _, name := range k.Models {
+			if name == "*" {
+				k.all = true
+				continue
+			}
+			if !slices.Contains(registered, name) {
+				return nil, fmt.Errorf("%s: key %q: unknown model %q; registered: %s",
+					path, k.Name, name, strings.Join(registered, ", "))
+			}
+			k.allowed[name] = true
+		}
+	}
+	return keys, nil
+}
+
+// authKey returns the key whose secret is got. Every key is compared, in
+// constant time, so the time taken doesn't tell which one came close.
+func (s *Server) authKey(got string) (*apiKey, error) {
+	if got == "" {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "API key required in "+apiKeyHeader)
+	}
+	var found *apiKey
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(k.Key)) == 1 {
+			found = k
+		}
+	}
+	if found == nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "unknown API key")
+	}
+	found.requests.Add(1)
+	return found, nil
+}
+
+// requireKey guards the inference routes with -api-keys and keeps the key
+// for allowModel. With no keys configured the routes are open.
+func (s *Server) requireKey(c *fiber.Ctx) error {
+	if s.keys == nil {
+		return c.Next()
+	}
+	k, err := s.authKey(c.Get(apiKeyHeader))
+	if err != nil {
+		return err
+	}
+	c.Locals("apiKey", k)
+	return c.Next()
+}
+
+// grpcKey is requireKey for a gRPC call, which sends the key as x-api-key
+// metadata. It returns nil with no keys configured.
+func (s *Server) grpcKey(ctx context.Context) (*apiKey, error) {
+	if s.keys == nil {
+		return nil, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	got := ""
+	if v := md.Get(apiKeyHeader); len(v) > 0 {
+		got = v[0]
+	}
+	return s.authKey(got)
+}
+
+func keyOf(c *fiber.Ctx) *apiKey {
+	k, _ := c.Locals("apiKey").(*apiKey)
+	return k
+}
+
+// allow fails with 403 unless k may use every one of names, and otherwise
+// counts a use of each. A nil k (no -api-keys) allows everything.
+func (k *apiKey) allow(names ...string) error {
+	if k == nil {
+		return nil
+	}
+	for _, name := range names {
+		if !k.all && !k.allowed[name] {
+			k.forbidden.Add(1)
+			return fiber.NewError(fiber.StatusForbidden,
+				fmt.Sprintf("API key %q may not use model %q", k.Name, name))
+		}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, name := range names {
+		k.byModel[name]++
+	}
+	k.lastUsed = time.Now()
+	return nil
+}
+
+// allowModel is allow for the request's key and m. The -fallback-model
+// isn't something requests pick: it stands in for the primary under load,
+// so it is checked, and counted, as the primary.
+func (s *Server) allowModel(c *fiber.Ctx, m *Model) error {
+	return keyOf(c).allow(s.scopeName(m))
+}
+
+func (s *Server) scopeName(m *Model) string {
+	if s.degrade != nil && m == s.degrade.fallback {
+		return s.primaryModel().ModelName
+	}
+	return m.ModelName
+}
+
+// allowEnsemble checks the request's key against every registered model.
+func (s *Server) allowEnsemble(c *fiber.Ctx) error {
+	return keyOf(c).allow(s.modelOrder...)
+}
+
+type keysResp struct {
+	Enabled bool       `json:"enabled"` // false without -api-keys
+	Keys    []keyUsage `json:"keys"`
+}
+
+type keyUsage struct {
+	Name      string           `json:"name"`
+	Models    []string         `json:"models"`
+	Requests  int64            `json:"requests"`  // authenticated requests
+	Forbidden int64            `json:"forbidden"` // refused with 403
+	ByModel   map[string]int64 `json:"by_model"`  // allowed uses per model
+	LastUsed  *apiTime         `json:"last_used"` // last allowed use; null if none
+}
+
+// handleKeys reports each -api-keys entry's usage, in file order.
+func (s *Server) handleKeys(c *fiber.Ctx) error {
+	out := make([]keyUsage, 0, len(s.keys))
+	for _, k := range s.keys {
+		u := keyUsage{
+			Name:      k.Name,
+			Models:    k.Models,
+			Requests:  k.requests.Load(),
+			Forbidden: k.forbidden.Load(),
+		}
+		k.mu.Lock()
+		u.ByModel = maps.Clone(k.byModel)
+		if !k.lastUsed.IsZero() {
+			t := apiTime(k.lastUsed)
+			u.LastUsed = &t
+		}
+		k.mu.Unlock()
+		out = append(out, u)
+	}
+	return c.JSON(keysResp{Enabled: s.keys != nil, Keys: out})
+}
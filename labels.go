@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Class labels
+// ─────────────────────────────────────────────────────────────
+
+// allowLabelGaps is set by -allow-label-gaps before any labels are loaded.
+var allowLabelGaps bool
+
+// parseLabels reads a JSON array with one entry per output index: either
+// plain class names, or objects with a "name" plus string metadata such as
+// {"name":"tabby","genus":"felis"} that group_by can aggregate over.
+func parseLabels(data []byte, classes int) ([]string, []map[string]string, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("labels: %w", err)
+	}
+	if len(raw) > classes {
+		return nil, nil, fmt.Errorf("labels: have %d names for %d classes", len(raw), classes)
+	}
+	labels := make([]string, classes)
+	var meta []map[string]string
+	for i, r := range raw {
+		if json.Unmarshal(r, &labels[i]) == nil {
+			continue
+		}
+		var m map[string]string
+		if err := json.Unmarshal(r, &m); err != nil {
+			return nil, nil, fmt.Errorf("labels[%d]: want a name or an object of strings", i)
+		}
+		if m["name"] == "" {
+			return nil, nil, fmt.Errorf("labels[%d]: object has no \"name\"", i)
+		}
+		if meta == nil {
+			meta = make([]map[string]string, classes)
+		}
+		labels[i], meta[i] = m["name"], m
+	}
+	if err := checkLabels(labels, len(raw)); err != nil {
+		return nil, nil, err
+	}
+	return labels, meta, nil
+}
+
+// checkLabels catches label files that would load but display wrongly.
+// Classes without a name (null, "", or past the end of a short file) are an
+// error unless -allow-label-gaps, since every later name may be off by one.
+// A name shared by several classes is only a warning: it can be deliberate,
+// but a client can't tell those classes apart, nor pick one by name.
+func checkLabels(labels []string, given int) error {
+	var missing []int
+	byName := map[string][]int{}
+	var names []string // in first-index order, for stable warnings
+	for i, name := range labels {
+		if name == "" {
+			missing = append(missing, i)
+			continue
+		}
+		if byName[name] == nil {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], i)
+	}
+	for _, name := range names {
+		if ix := byName[name]; len(ix) > 1 {
+			log.Printf("WARN: labels: %q names classes %s", name, indexList(ix))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("labels: no name for classes %s", indexList(missing))
+	if given < len(labels) {
+		msg += fmt.Sprintf(" (have %d names for %d classes)", given, len(labels))
+	}
+	if !allowLabelGaps {
+		return fmt.Errorf("%s; fix the file or pass -allow-label-gaps to serve them unlabeled", msg)
+	}
+	log.Printf("WARN: %s; serving them unlabeled (-allow-label-gaps)", msg)
+	return nil
+}
+
+// indexList formats class indices for a message, eliding long runs.
+func indexList(ix []int) string {
+	const show = 10
+	parts := make([]string, 0, min(len(ix), show)+1)
+	for _, i := range ix[:min(len(ix), show)] {
+		parts = append(parts, strconv.Itoa(i))
+	}
+	if len(ix) > show {
+		parts = append(parts, fmt.Sprintf("... (%d more)", len(ix)-show))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func loadLabels(path string, classes int) ([]string, []map[string]string, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseLabels(b, classes)
+}
+
+// label returns the name for class i, or "" without labels.
+func (m *Model) label(i int) string {
+	if i < 0 || i >= len(m.Labels) {
+		return ""
+	}
+	return m.Labels[i]
+}
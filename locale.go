@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Localized labels (Accept-Language)
+// ─────────────────────────────────────────────────────────────
+
+// Localized names live in label metadata as "name.<lang>" keys, e.g.
+// {"name":"cat","name.fr":"chat","name.pt-br":"gato"}. Lookups are
+// case-insensitive; classes without a translation keep "name".
+const localeKeyPrefix = "name."
+
+// locales lists the languages any label has a translation for.
+func (m *Model) locales() map[string]bool {
+	out := map[string]bool{}
+	for _, meta := range m.LabelMeta {
+		for k := range meta {
+			if lang, ok := strings.CutPrefix(strings.ToLower(k), localeKeyPrefix); ok && lang != "" {
+				out[lang] = true
+			}
+		}
+	}
+	return out
+}
+
+// localeList is locales() sorted, for /config.
+func (m *Model) localeList() []string {
+	out := []string{}
+	for lang := range m.locales() {
+		out = append(out, lang)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// pickLocale returns the best supported language for an Accept-Language
+// header, or "" for the default names. Tags are tried in q order, each as
+// given and then by its primary subtag (pt-BR → pt).
+func (m *Model) pickLocale(header string) string {
+	if header == "" || len(m.LabelMeta) == 0 {
+		return ""
+	}
+	have := m.locales()
+	if len(have) == 0 {
+		return ""
+	}
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		t := tag{lang: strings.ToLower(strings.TrimSpace(lang)), q: 1}
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(v, 64); err == nil {
+				t.q = q
+			}
+		}
+		if t.lang != "" && t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(a, b int) bool { return tags[a].q > tags[b].q })
+	for _, t := range tags {
+		if t.lang == "*" {
+			return ""
+		}
+		if have[t.lang] {
+			return t.lang
+		}
+		if base, _, ok := strings.Cut(t.lang, "-"); ok && have[base] {
+			return base
+		}
+	}
+	return ""
+}
+
+// labelsIn returns the class names in lang, falling back per class to the
+// default name. lang "" is the default set.
+func (m *Model) labelsIn(lang string) []string {
+	if lang == "" {
+		return m.Labels
+	}
+	out := make([]string, len(m.Labels))
+	for i := range out {
+		out[i] = m.Labels[i]
+		if i >= len(m.LabelMeta) {
+			continue
+		}
+		for k, v := range m.LabelMeta[i] {
+			if strings.EqualFold(k, localeKeyPrefix+lang) && v != "" {
+				out[i] = v
+			}
+		}
+	}
+	return out
+}
+
+// requestLabels resolves the request's Accept-Language against m's labels
+// and returns the localized names, or nil to keep the defaults. It sets
+// Content-Language on the response when a translation is used.
+func (m *Model) requestLabels(c *fiber.Ctx) []string {
+	c.Vary(fiber.HeaderAcceptLanguage)
+	lang := m.pickLocale(c.Get(fiber.HeaderAcceptLanguage))
+	if lang == "" {
+		return nil
+	}
+	c.Set(fiber.HeaderContentLanguage, lang)
+	return m.labelsIn(lang)
+}
+
+// relabel swaps label for names[i]; no-op when names is nil.
+func relabel(names []string, i int, label *string) {
+	if names != nil && i >= 0 && i < len(names) {
+		*label = names[i]
+	}
+}
+
+func relabelTopK(names []string, ks []classScore) {
+	for i := range ks {
+		relabel(names, ks[i].Index, &ks[i].Label)
+	}
+}
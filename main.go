@@ -1,27 +1,40 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	htmleng "github.com/gofiber/template/html/v2"
 	"github.com/openfluke/paragon/v3"
+	"golang.org/x/text/unicode/norm"
+	"google.golang.org/grpc"
 )
 
 // ─────────────────────────────────────────────────────────────
@@ -34,84 +47,413 @@ var templatesFS embed.FS
 //go:embed web/static/*
 var staticFS embed.FS
 
+// cpuOnly is set by -deterministic before any model is mounted and never
+// changes afterwards.
+var cpuOnly bool
+
 // ─────────────────────────────────────────────────────────────
 // Server state
 // ─────────────────────────────────────────────────────────────
 
 type Server struct {
-	NN         *paragon.Network[float32]
+	modelShape                       // the primary's; fixed for the process lifetime
+	primary    atomic.Pointer[Model] // swapped by reloads; read via primaryModel
+	reloading  atomic.Bool           // set while a reload runs (one at a time)
+	reqs       requests              // in-flight requests (/admin/inflight)
+	source     modelSource
+
+	models     *modelCache // -models, by name; the primary is held apart
+	modelOrder []string    // primary first, then -models
+
+	slots *scheduler // GPU slot IDs; take one to submit, hand it back when done
+	lanes struct {   // per-endpoint budgets within slots
+		infer, blast *lane
+	}
+	gpuMu sync.Mutex // serialize GPU if backend isn’t re-entrant
+
+	degrade *degrader // nil unless -fallback-model is set
+	breaker *breaker
+	flights flightGroup
+	sampler *sampler      // nil unless -sample-rate > 0
+	audit   *auditLog     // nil unless -audit-log is set
+	statsd  *statsdPusher // nil unless -statsd-addr is set
+	bench   *benchResult  // nil unless -selfbench ran
+
+	throttle *throttle // nil unless -global-rps is set
+
+	preset   string
+	pre      preprocess
+	modelPre map[string]preprocess // <model>.preprocess.json overrides by model name; fixed after startup
+	strict   atomic.Bool           // input mode: reject out-of-range pixels instead of clamping
+	stats    stats
+
+	adminToken   string
+	keys         []*apiKey     // -api-keys; nil leaves the inference routes open
+	reqTimeout   time.Duration // total per-request deadline (0 = none)
+	queueTimeout time.Duration // max wait for a GPU slot (0 = none)
+	reloadDrain  time.Duration // max wait for the old model's requests on reload
+	maxBatch     int           // max forwards one request may trigger
+	maxTopK      int           // top_k cap; larger requests are clamped (0 = none)
+	maxProbs     int           // unset top_k means this many classes on bigger models (0 = full probs)
+
+	maxUploadPixels int // /infer/upload image size budget
+
+	cpuRouted atomic.Uint64 // forwards considered for -cpu-share
+
+	corpus [][][]float64 // -blast-corpus inputs, preprocessed; nil without one
+
+	inflight int64
+	queued   int64 // requests waiting on sem
+	started  time.Time
+	quit     chan struct{} // closed on shutdown; ends long-lived streams
+}
+
+// modelShape is what requests are validated against: the input/output
+// shape and class labels. Every model the server holds shares the
+// primary's, and a reload must keep it.
+type modelShape struct {
 	InputW     int
 	InputH     int
 	ClassCount int
-	ModelPath  string
-	ModelName  string
+	Labels     []string            // optional class names, by output index
+	LabelMeta  []map[string]string // optional per-class metadata (group_by keys)
+}
 
-	sem   chan struct{} // bound concurrent submissions
-	gpuMu sync.Mutex    // serialize GPU if backend isn’t re-entrant
+// Model is a loaded network plus the shapes derived from it.
+type Model struct {
+	NN *paragon.Network[float32]
+	modelShape
+	ModelPath string
+	ModelName string
+	OutputAct string // activation of the output layer
+	// OutputIsProb: outputs are already probabilities (softmax output layer,
+	// or detected at load), so probs must not softmax them again.
+	OutputIsProb bool
+	GPU          bool       // mounted on WebGPU at load; NN.WebGPUNative may flip under gpuMu
+	SHA256       string     // hex digest of the network JSON
+	Quant        *quantInfo // set for int8/uint8 models, dequantized at load
 
-	inflight int64
-	started  time.Time
+	CPU   *paragon.Network[float32] // -cpu-share replica of a GPU model; nil otherwise
+	cpuMu sync.Mutex                // serializes forwards on CPU
+
+	batchPool chan *paragon.Network[float32] // -batch-workers replicas of a CPU model; nil otherwise
 }
 
 func main() {
 	addr := flag.String("addr", ":8080", "listen address")
+	unixSock := flag.String("unix", "", "listen on this Unix domain socket instead of TCP")
+	grpcAddr := flag.String("grpc-addr", "", "also serve the gRPC Inference service on this address, e.g. :9090 (optional)")
 	modelPath := flag.String("model", "./models/mnist_model.json", "path to saved Paragon JSON model")
-	maxGPU := flag.Int("maxgpu", 4, "max concurrent GPU submissions")
+	bundlePath := flag.String("bundle", "", "zip/tar bundle with model.json, labels.json and manifest.json (replaces -model)")
+	labelsPath := flag.String("labels", "", "JSON array of class names for -model")
+	flag.BoolVar(&allowLabelGaps, "allow-label-gaps", false, "load label files with unnamed classes (null, \"\" or a short array) instead of failing")
+	inputW := flag.Int("input-w", 0, "expected model input width; startup fails if the model disagrees (0 = derive)")
+	inputH := flag.Int("input-h", 0, "expected model input height; startup fails if the model disagrees (0 = derive)")
+	inputExprSrc := flag.String("input-expr", "", `per-value transform applied after preprocessing, e.g. "v*2-1" or "log(v+1)" (off when empty)`)
+	preset := flag.String("preset", "", "input preprocessing preset: mnist, cifar or imagenet (default: clamp only)")
+	inputMode := flag.String("input-mode", inputClamp, "clamp out-of-range pixels to [0,1] or reject them (strict); changeable via /admin/input-mode")
+	maxGPU := flag.Int("maxgpu", 4, "max concurrent GPU submissions (0 = no limit)")
+	maxGPUInfer := flag.Int("maxgpu-infer", 0, "of -maxgpu, max slots /infer, /infer/embed and gRPC may hold (0 = no own budget)")
+	maxGPUBlast := flag.Int("maxgpu-blast", 0, "of -maxgpu, max slots /blast may hold, so load tests leave room for /infer (0 = no own budget)")
+	extraModels := flag.String("models", "", "comma-separated extra model paths, selectable per request and used by ensembles")
+	modelCacheSize := flag.Int("model-cache", 0, "max -models entries kept loaded; the least recently used is unloaded and reloaded on demand (0 = all)")
+	fallbackPath := flag.String("fallback-model", "", "smaller model to serve while under load (optional)")
+	fallbackQueue := flag.Int("fallback-queue", 8, "queue depth that switches to the fallback model")
+	fallbackP99 := flag.Float64("fallback-p99-ms", 250, "p99 latency (ms) that switches to the fallback model")
+	adminToken := flag.String("admin-token", "", "bearer token required for /admin/* (empty = open)")
+	apiKeysPath := flag.String("api-keys", "", `JSON file of [{"name","key","models"}]: inference routes then need an X-API-Key allowed the model they use`)
+	reqTimeout := flag.Duration("timeout", 0, "total per-request deadline, e.g. 2s (0 = none)")
+	queueTimeout := flag.Duration("queue-timeout", 0, "max time to wait for a GPU slot before 503 (0 = none)")
+	globalRPS := flag.Float64("global-rps", 0, "cap on inferences (forwards) per second across all clients; over it requests queue up to -global-rps-wait, then get 429 (0 = off)")
+	globalBurst := flag.Int("global-burst", 0, "forwards -global-rps lets through at once after a quiet spell (0 = one second's worth)")
+	globalWait := flag.Duration("global-rps-wait", 100*time.Millisecond, "longest a request queues for a -global-rps token before 429")
+	maxBatch := flag.Int("max-batch", 256, "max samples per /infer-batch and forwards per /explain")
+	maxTopK := flag.Int("max-topk", 100, "largest top_k served; bigger requests are clamped with a warning (0 = no cap)")
+	maxUploadPixels := flag.Int("max-upload-pixels", 2048*2048, "largest image (width×height) /infer/upload decodes; bigger uploads fail with 413 before decoding")
+	blastCorpus := flag.String("blast-corpus", "", "JSONL file of inputs /blast cycles through in order, for reproducible load tests with varied inputs")
+	maxProbs := flag.Int("max-probs", 100, "without top_k, return only the top this-many classes instead of probs on larger models (0 = always full probs)")
+	sampleRate := flag.Float64("sample-rate", 0, "fraction of predictions (0..1) written to -sample-file")
+	sampleFile := flag.String("sample-file", "./data/samples.jsonl", "JSONL file for sampled predictions")
+	statsdAddr := flag.String("statsd-addr", "", "push counters and latency timers to this StatsD/DogStatsD host:port over UDP (off when empty)")
+	statsdPrefix := flag.String("statsd-prefix", "paragon", "metric name prefix for -statsd-addr")
+	statsdEvery := flag.Duration("statsd-interval", 10*time.Second, "how often -statsd-addr metrics are flushed")
+	auditPath := flag.String("audit-log", "", "append a hash-chained record of every prediction to this JSONL file (off when empty)")
+	concurrency := flag.Int("concurrency", fiber.DefaultConcurrency, "max concurrent connections")
+	readBuf := flag.Int("read-buffer", 4096, "per-connection read buffer bytes (also caps request header size)")
+	writeBuf := flag.Int("write-buffer", 4096, "per-connection write buffer bytes")
+	noKeepAlive := flag.Bool("no-keepalive", false, "close connections after each response")
+	breakerFailures := flag.Int("breaker-failures", 5, "GPU failures within -breaker-window that open the circuit breaker")
+	breakerWindow := flag.Duration("breaker-window", 30*time.Second, "window for counting GPU failures")
+	breakerCooldown := flag.Duration("breaker-cooldown", 30*time.Second, "how long the breaker stays open before probing the GPU")
+	breakerFailFast := flag.Bool("breaker-failfast", false, "while the breaker is open, fail with 503 instead of running on CPU")
+	webDir := flag.String("web-dir", "", "serve templates/ and static/ from this directory instead of the embedded copies (front-end dev)")
+	flag.IntVar(&fetchAttempts, "model-fetch-attempts", 5, "tries per http(s) -model/-models download before startup fails")
+	flag.DurationVar(&fetchBackoff, "model-fetch-backoff", time.Second, "wait before the first download retry; doubles on each further one")
+	writeTimeout := flag.Duration("write-timeout", 60*time.Second, "time a client has to read a response; streamed ones get it per line")
+	minWriteRate := flag.Int("min-write-rate", 64<<10, "bytes/s a client must keep reading on responses over 64 KiB, on top of -write-timeout (0: off)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 5*time.Second, "on SIGINT/SIGTERM, how long to let in-flight requests finish before closing their connections")
+	selfBench := flag.Bool("selfbench", false, "time GPU vs CPU forwards at startup and log the speedup")
+	flag.StringVar(&timeFormat, "time-format", timeRFC3339, "response timestamps: rfc3339, unix (seconds) or unix_ms")
+	flag.StringVar(&softmaxDefault, "softmax-default", softmaxAuto, `softmax the returned scores when a request doesn't say: auto or true (when the outputs aren't already probabilities), false (raw outputs) or force (unless the output layer is softmax, for outputs wrongly detected as probabilities)`)
+	flag.StringVar(&jsonCase, "json-case", caseSnake, "response key style: snake or camel (per request: X-JSON-Case header)")
+	envelopeJSON := flag.Bool("envelope", false, `wrap JSON responses as {"ok":true,"data":…} and errors as {"ok":false,"error":…}`)
+	deterministic := flag.Bool("deterministic", false, "run every forward on the CPU, with no load-based model switching, so outputs are bit-stable (CI)")
+	flag.IntVar(&batchWorkers, "batch-workers", 1, "goroutines per /infer-batch on CPU models, each with its own copy of the network (1: sequential)")
+	flag.Float64Var(&cpuShare, "cpu-share", 0, "fraction of forwards (0–1) to run on a CPU replica of each GPU model, in parallel with the GPU")
+	flag.BoolVar(&strictVersion, "strict-version", false, `refuse models whose "version" marker doesn't match the paragon build, instead of warning`)
+	watch := flag.Bool("watch", false, "reload the primary model (or -bundle) when its file changes")
+	reloadDrain := flag.Duration("reload-drain", 30*time.Second, "on reload, how long to let requests on the old model finish on GPU before retiring it")
 	flag.Parse()
 
-	// 1) Load model (Paragon-style)
-	nn, inW, inH, classes, err := loadParagonModel(*modelPath)
+	if *unixSock != "" && flagSet("addr") {
+		log.Fatalf("use either -addr or -unix, not both")
+	}
+	if *bundlePath != "" && (flagSet("model") || *labelsPath != "") {
+		log.Fatalf("-bundle already carries the model and labels; drop -model/-labels")
+	}
+	if *deterministic && *fallbackPath != "" {
+		log.Fatalf("-deterministic can't be combined with -fallback-model: switching models under load changes outputs")
+	}
+	cpuOnly = *deterministic
+	if fetchAttempts < 1 || fetchBackoff < 0 {
+		log.Fatalf("-model-fetch-attempts must be >= 1 and -model-fetch-backoff >= 0")
+	}
+	if *writeTimeout <= 0 || *minWriteRate < 0 {
+		log.Fatalf("-write-timeout must be > 0 and -min-write-rate >= 0")
+	}
+	if *inputExprSrc != "" {
+		e, err := parseValueExpr(*inputExprSrc)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		inputExpr = e
+	}
+	if *statsdEvery <= 0 {
+		log.Fatalf("-statsd-interval must be > 0")
+	}
+	if *shutdownTimeout <= 0 {
+		log.Fatalf("-shutdown-timeout must be > 0")
+	}
+	if jsonCase != caseSnake && jsonCase != caseCamel {
+		log.Fatalf("-json-case must be snake or camel")
+	}
+	if timeFormat != timeRFC3339 && timeFormat != timeUnix && timeFormat != timeUnixMs {
+		log.Fatalf("-time-format must be rfc3339, unix or unix_ms")
+	}
+	if softmaxDefault != softmaxAuto && softmaxDefault != softmaxOn && softmaxDefault != softmaxOff && softmaxDefault != softmaxForce {
+		log.Fatalf("-softmax-default must be auto, true, false or force")
+	}
+	if batchWorkers < 1 || batchWorkers > 256 {
+		log.Fatalf("-batch-workers must be between 1 and 256, got %d", batchWorkers)
+	}
+	if cpuShare < 0 || cpuShare > 1 {
+		log.Fatalf("-cpu-share must be between 0 and 1")
+	}
+	if err := checkGPUFlags(*maxGPU, *maxGPUInfer, *maxGPUBlast); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// 1) Load model (Paragon-style), 2) mount on GPU once, 3) warmup
+	var m *Model
+	var err error
+	if *bundlePath != "" {
+		m, err = loadBundle(*bundlePath, nil)
+	} else {
+		m, err = loadModel(*modelPath, nil)
+	}
 	if err != nil {
 		log.Fatalf("failed to load model: %v", err)
 	}
+	if (*inputW != 0 && *inputW != m.InputW) || (*inputH != 0 && *inputH != m.InputH) {
+		log.Fatalf("model %s takes %dx%d (w×h) input but -input-w/-input-h say %dx%d",
+			m.ModelName, m.InputW, m.InputH, *inputW, *inputH)
+	}
+	if *labelsPath != "" {
+		if m.Labels, m.LabelMeta, err = loadLabels(*labelsPath, m.ClassCount); err != nil {
+			log.Fatalf("failed to load labels: %v", err)
+		}
+	}
 
-	// 2) Mount on GPU once
-	nn.WebGPUNative = true
-	if err := nn.InitializeOptimizedGPU(); err != nil {
-		log.Printf("WARN: WebGPU init failed: %v — falling back to CPU.", err)
-		nn.WebGPUNative = false
-	} else {
-		log.Printf("GPU initialized.")
+	s := &Server{
+		modelShape:   m.modelShape,
+		source:       modelSource{path: *modelPath},
+		slots:        newScheduler(*maxGPU),
+		adminToken:   *adminToken,
+		reqTimeout:   *reqTimeout,
+		queueTimeout: *queueTimeout,
+		reloadDrain:  *reloadDrain,
+		maxBatch:     *maxBatch,
+		maxTopK:      *maxTopK,
+		maxProbs:     *maxProbs,
+
+		maxUploadPixels: *maxUploadPixels,
+		breaker: &breaker{
+			threshold: *breakerFailures,
+			window:    *breakerWindow,
+			cooldown:  *breakerCooldown,
+			failFast:  *breakerFailFast,
+		},
+		started: time.Now(),
+		quit:    make(chan struct{}),
+	}
+	if *bundlePath != "" {
+		s.source = modelSource{path: *bundlePath, bundle: true}
+	}
+	s.lanes.infer, s.lanes.blast = newLane("infer", *maxGPUInfer), newLane("blast", *maxGPUBlast)
+	s.primary.Store(m)
+	s.stats.slotUse = make([]int64, *maxGPU)
+	if *modelCacheSize < 0 {
+		log.Fatalf("-model-cache must be >= 0 (0 keeps every -models entry loaded)")
+	}
+	s.models = newModelCache(*modelCacheSize, s.retire, &s.gpuMu)
+	if s.modelOrder, err = loadRegistry(m, *extraModels, s.models); err != nil {
+		log.Fatalf("failed to load models: %v", err)
+	}
+	if *apiKeysPath != "" {
+		if s.keys, err = loadAPIKeys(*apiKeysPath, s.modelOrder); err != nil {
+			log.Fatalf("-api-keys: %v", err)
+		}
+		log.Printf("Loaded %d API keys; inference routes need %s.", len(s.keys), apiKeyHeader)
 	}
 
-	// 3) Warmup (zeros)
-	if inW > 0 && inH > 0 {
-		z := makeImage(inW, inH, 0)
-		nn.Forward(z)
-		_ = nn.ExtractOutput()
+	if *preset != "" {
+		p, ok := presets[*preset]
+		if !ok {
+			log.Fatalf("unknown -preset %q (want one of %v)", *preset, presetNames())
+		}
+		s.preset, s.pre = *preset, p
+	}
+	if err := s.pre.validate(m.InputW); err != nil {
+		log.Fatalf("preset %s: %v", *preset, err)
+	}
+	if s.modelPre, err = loadModelPreprocess(m, s.models); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if inputExpr != nil {
+		if err := inputExpr.checkPre(&s.pre); err != nil {
+			log.Fatalf("%v", err)
+		}
+		for name, p := range s.modelPre {
+			if err := inputExpr.checkPre(&p); err != nil {
+				log.Fatalf("%s: %v", name, err)
+			}
+		}
+		log.Printf("Input values are transformed by %q after preprocessing.", inputExpr.src)
+	}
+	if _, err := s.setInputMode(*inputMode); err != nil {
+		log.Fatalf("-input-mode: %v", err)
 	}
 
-	s := &Server{
-		NN:         nn,
-		InputW:     inW,
-		InputH:     inH,
-		ClassCount: classes,
-		ModelPath:  filepath.Clean(*modelPath),
-		ModelName:  filepath.Base(*modelPath),
-		sem:        make(chan struct{}, *maxGPU),
-		started:    time.Now(),
+	if *blastCorpus != "" {
+		if s.corpus, err = s.loadCorpus(*blastCorpus); err != nil {
+			log.Fatalf("-blast-corpus: %v", err)
+		}
+		log.Printf("Loaded %d /blast inputs from %s.", len(s.corpus), *blastCorpus)
 	}
 
-	// 4) Views engine from embedded FS
+	if *globalRPS < 0 || *globalBurst < 0 || *globalWait < 0 {
+		log.Fatalf("-global-rps, -global-burst and -global-rps-wait must be >= 0")
+	}
+	if *globalRPS > 0 {
+		s.throttle = newThrottle(*globalRPS, *globalBurst, *globalWait)
+		log.Printf("Inferences capped at %g/s (burst %g, queueing up to %s).", *globalRPS, s.throttle.burst, *globalWait)
+	}
+
+	if *sampleRate < 0 || *sampleRate > 1 {
+		log.Fatalf("-sample-rate must be within 0..1")
+	}
+	if *sampleRate > 0 {
+		if s.sampler, err = newSampler(*sampleFile, *sampleRate); err != nil {
+			log.Fatalf("failed to open sample file: %v", err)
+		}
+		log.Printf("Sampling %.2f%% of predictions to %s.", *sampleRate*100, *sampleFile)
+	}
+	if *auditPath != "" {
+		if s.audit, err = openAuditLog(*auditPath); err != nil {
+			log.Fatalf("audit log: %v", err)
+		}
+		seq, _ := s.audit.written()
+		log.Printf("Audit log %s: %d records, appending.", *auditPath, seq)
+	}
+	if *statsdAddr != "" {
+		if s.statsd, err = startStatsd(s, *statsdAddr, *statsdPrefix, *statsdEvery); err != nil {
+			log.Fatalf("-statsd-addr: %v", err)
+		}
+		log.Printf("Pushing metrics to StatsD at %s every %s (prefix %q).", *statsdAddr, *statsdEvery, *statsdPrefix)
+	}
+
+	if *selfBench {
+		s.runSelfBench(m)
+	}
+
+	// Optional load-shedding model
+	if *fallbackPath != "" {
+		fb, err := loadModel(*fallbackPath, nil)
+		if err != nil {
+			log.Fatalf("failed to load fallback model: %v", err)
+		}
+		if err := sameShape(m, fb); err != nil {
+			log.Fatalf("fallback model: %v", err)
+		}
+		fb.Labels, fb.LabelMeta = m.Labels, m.LabelMeta
+		s.degrade = &degrader{fallback: fb, maxQueue: *fallbackQueue, maxP99: *fallbackP99}
+		go s.degrade.run(s)
+		log.Printf("Fallback model %s armed (queue≥%d or p99≥%.0fms).", fb.ModelName, *fallbackQueue, *fallbackP99)
+	}
+
+	// 4) Views engine from embedded FS (or -web-dir on disk)
 	tmplFS, err := fs.Sub(templatesFS, "web/templates")
 	if err != nil {
 		log.Fatalf("embed FS sub mount: %v", err)
 	}
+	staticRoot, staticPrefix := http.FS(staticFS), "web/static"
+	if *webDir != "" {
+		for _, sub := range []string{"templates", "static"} {
+			if fi, err := os.Stat(filepath.Join(*webDir, sub)); err != nil || !fi.IsDir() {
+				log.Fatalf("-web-dir %s: missing %s/ directory", *webDir, sub)
+			}
+		}
+		tmplFS = os.DirFS(filepath.Join(*webDir, "templates"))
+		staticRoot, staticPrefix = http.Dir(filepath.Join(*webDir, "static")), ""
+		log.Printf("Serving templates and static assets from %s (live).", *webDir)
+	}
 	engine := htmleng.NewFileSystem(http.FS(tmplFS), ".html")
 	engine.AddFunc("now", func() int { return time.Now().Year() })
 	engine.Reload(true) // dev
 
 	// 5) Fiber app
-	app := fiber.New(fiber.Config{
-		Views:        engine,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 60 * time.Second,
-	})
+	cfg := fiber.Config{
+		Views:            engine,
+		ReadTimeout:      15 * time.Second,
+		WriteTimeout:     *writeTimeout,
+		Concurrency:      *concurrency,
+		ReadBufferSize:   *readBuf,
+		WriteBufferSize:  *writeBuf,
+		DisableKeepalive: *noKeepAlive,
+
+		// /infer/upload reads its body as it arrives; see bufferBody.
+		BodyLimit:                    maxBodyBytes,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	}
+	if *envelopeJSON {
+		cfg.ErrorHandler = envelopeError
+	}
+	app := fiber.New(cfg)
 
-	// Static (embedded)
+	// A backend panic fails one request instead of the whole process.
+	app.Use(recover.New())
+	app.Use(writeWindow(*writeTimeout, *minWriteRate))
+	app.Use(bufferBody(maxBodyBytes))
+	app.Use(keyCase)
+	if *envelopeJSON {
+		app.Use(envelope)
+	}
+
+	// Static (embedded unless -web-dir)
 	app.Use("/static", filesystem.New(filesystem.Config{
-		Root:       http.FS(staticFS),
-		PathPrefix: "web/static",
+		Root:       staticRoot,
+		PathPrefix: staticPrefix,
 		Browse:     false,
 	}))
 
@@ -129,69 +471,309 @@ func main() {
 	// JSON service endpoints
 	app.Get("/health", s.handleHealth)
 	app.Get("/config", s.handleConfig)
-	app.Post("/infer", s.handleInfer)              // one sample
-	app.Post("/infer-batch", s.handleInferBatch)   // looped demo
-	app.Post("/blast", s.handleBlast)              // N concurrent forwards
-	app.Post("/save-session", s.handleSaveSession) // <-- NEW: persist session JSON
+	app.Get("/stats", s.handleStats)
+	app.Get("/stats/stream", s.handleStatsStream) // SSE snapshots for live gauges
+	app.Get("/model", s.handleModel)
+	app.Get("/model/stats", s.requireKey, s.handleModelStats)                 // per-layer weight mean, std, L2 norm, NaN/Inf counts
+	app.Get("/openapi.json", s.handleOpenAPI)                                 // OpenAPI 3 description of these routes
+	app.Post("/infer", s.requireKey, s.track, s.handleInfer)                  // one sample
+	app.Post("/infer/embed", s.requireKey, s.track, s.handleEmbed)            // prediction + penultimate-layer embedding
+	app.Post(uploadPath, s.requireKey, s.track, s.handleUpload)               // multipart image file, streamed
+	app.Post(scorePath, s.requireKey, s.track, s.handleScore)                 // NDJSON in, NDJSON out, streamed
+	app.Post("/infer-batch", s.requireKey, s.track, s.handleInferBatch)       // looped demo
+	app.Post("/blast", s.requireKey, s.track, s.handleBlast)                  // N concurrent forwards
+	app.Post("/explain", s.requireKey, s.track, s.handleExplain)              // occlusion saliency
+	app.Post("/interpolate", s.requireKey, s.track, s.handleInterpolate)      // class flips along a→b
+	app.Post("/calibrate", s.requireKey, s.track, s.handleCalibrate)          // reliability diagram + ECE over a labeled set
+	app.Post("/profile/layers", s.requireKey, s.track, s.handleProfileLayers) // per-layer CPU forward timing
+	app.Post("/verify", s.requireKey, s.track, s.handleVerify)                // GPU vs CPU output divergence over a set
+	app.Post("/rpc", s.requireKey, s.track, s.handleRPC)                      // JSON-RPC 2.0: infer, config, health
+	app.Post("/analyze", s.requireKey, s.handleAnalyze)                       // dataset input statistics, no forwards
+	app.Post("/save-session", s.requireKey, s.handleSaveSession)              // <-- NEW: persist session JSON
+	app.Post("/sessions/:name/replay", s.requireKey, s.track, s.handleReplay)
+	app.Get("/sessions/:name", s.requireKey, s.handleGetSession) // saved session file, with Range for resuming
+
+	app.Get("/audit/verify", s.requireAdmin, s.handleAuditVerify) // check the -audit-log hash chain
+
+	// Admin
+	admin := app.Group("/admin", s.requireAdmin)
+	admin.Post("/warmup", s.handleWarmup)
+	admin.Post("/probe-batch", s.handleProbeBatch)
+	admin.Get("/input-mode", s.handleInputMode)
+	admin.Put("/input-mode", s.handleInputMode)
+	admin.Get("/config/export", s.handleConfigExport)
+	admin.Post("/config/import", s.handleConfigImport)
+	admin.Post("/reload", s.handleReload)
+	admin.Get("/inflight", s.handleInflight)
+	admin.Delete("/inflight/:id", s.handleCancelInflight)
+	admin.Get("/keys", s.handleKeys)
+
+	if *watch {
+		if err := s.watchModel(); err != nil {
+			log.Fatalf("-watch: %v", err)
+		}
+	}
+
+	var grpcSrv *grpc.Server
+	if *grpcAddr != "" {
+		if grpcSrv, err = s.serveGRPC(*grpcAddr); err != nil {
+			log.Fatalf("-grpc-addr: %v", err)
+		}
+	}
 
-	// graceful shutdown
+	// graceful shutdown: stop accepting, drain what's running for up to
+	// -shutdown-timeout, then release the GPU. Listen returns as soon as the
+	// listener closes, so main waits on shutdownDone before exiting.
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		sigc := make(chan os.Signal, 1)
 		signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
 		<-sigc
-		log.Printf("Shutting down...")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		log.Printf("Shutting down (draining for up to %s)...", *shutdownTimeout)
+		close(s.quit)
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
 		defer cancel()
-		if s.NN.WebGPUNative {
-			s.NN.CleanupOptimizedGPU()
+		grpcForced := make(chan bool, 1)
+		go func() { grpcForced <- stopGRPC(ctx, grpcSrv) }()
+		httpErr := app.ShutdownWithContext(ctx)
+		if forced := <-grpcForced || httpErr != nil; forced {
+			log.Printf("WARN: shutdown forced: requests still running after %s were cut off.", *shutdownTimeout)
+		} else {
+			log.Printf("Shutdown clean: drained in %s.", time.Since(start).Round(time.Millisecond))
+		}
+		// Under gpuMu, so a request the deadline cut off can't be mid-forward.
+		for _, rm := range append(s.models.residentModels(), s.primaryModel()) {
+			s.retire(rm)
 		}
-		_ = app.ShutdownWithContext(ctx)
+		if s.degrade != nil {
+			s.retire(s.degrade.fallback)
+		}
+		s.sampler.close()
+		s.audit.close()
+		s.statsd.close()
 	}()
 
+	if *unixSock != "" {
+		// A socket left behind by an unclean exit would make Listen fail.
+		if err := os.Remove(*unixSock); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Fatalf("remove stale socket: %v", err)
+		}
+		ln, err := net.Listen("unix", *unixSock)
+		if err != nil {
+			log.Fatalf("listen unix: %v", err)
+		}
+		defer os.Remove(*unixSock)
+		log.Printf("Listening on unix:%s", *unixSock)
+		if err := app.Listener(ln); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Fatalf("server error: %v", err)
+		}
+		<-shutdownDone
+		return
+	}
+
 	log.Printf("Listening on %s", *addr)
 	if err := app.Listen(*addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("server error: %v", err)
 	}
+	<-shutdownDone
+}
+
+// flagSet reports whether the named flag was passed on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 // ─────────────────────────────────────────────────────────────
 // Paragon model loading that matches your project’s APIs
 // ─────────────────────────────────────────────────────────────
 
-func loadParagonModel(path string) (*paragon.Network[float32], int, int, int, error) {
-	loaded, err := paragon.LoadNamedNetworkFromJSONFile(filepath.Clean(path))
+// loadModel loads a Paragon JSON model, mounts it on the GPU (falling back
+// to CPU) and runs a zero-input warmup forward.
+func loadModel(path string, gpu sync.Locker) (*Model, error) {
+	b, err := readModel(path)
+	if err != nil {
+		return nil, err
+	}
+	return b.mount(path, gpu)
+}
+
+// mountModel puts a parsed network on the GPU (CPU fallback), warms it up
+// and wraps it with its shapes. With -deterministic it stays on the CPU.
+// Paragon's WebGPU device and queue are shared by every network, so the
+// mount holds gpu (the server's gpuMu) against live forwards; it is nil
+// only at startup, before anything else runs.
+func mountModel(nn *paragon.Network[float32], inW, inH, classes int, path string, gpu sync.Locker) (*Model, error) {
+	if gpu != nil {
+		gpu.Lock()
+		defer gpu.Unlock()
+	}
+	nn.WebGPUNative = !cpuOnly
+	if cpuOnly {
+		log.Printf("%s runs on CPU (-deterministic).", filepath.Base(path))
+	} else if err := nn.InitializeOptimizedGPU(); err != nil {
+		log.Printf("WARN: WebGPU init failed for %s: %v — falling back to CPU.", filepath.Base(path), err)
+		nn.WebGPUNative = false
+	} else {
+		log.Printf("GPU initialized for %s.", filepath.Base(path))
+	}
+	return wrapModel(nn, inW, inH, classes, path)
+}
+
+// wrapModel warms up a mounted network and wraps it with its shapes and
+// replicas. Its forwards touch the GPU, so the caller holds gpuMu once
+// anything else may be running.
+func wrapModel(nn *paragon.Network[float32], inW, inH, classes int, path string) (*Model, error) {
+	var warm [][]float64 // successful warmup outputs, for the probability check
+	if inW > 0 && inH > 0 {
+		out, err := warmupOutput(nn, inW, inH, 0)
+		if err == nil {
+			warm = append(warm, out)
+		}
+		if classes == 0 {
+			// The output layer reports no cells; trust what a forward
+			// actually produces rather than serving argmax over nothing.
+			if err != nil || len(out) == 0 {
+				return nil, fmt.Errorf("%s: output layer has zero size (width×height = 0) and a warmup forward produced no output", filepath.Base(path))
+			}
+			classes = len(out)
+			log.Printf("WARN: %s: output layer reports zero size; using %d classes from the warmup forward.", filepath.Base(path), classes)
+		}
+	}
+
+	modelPath, modelName := filepath.Clean(path), filepath.Base(path)
+	if isModelURL(path) {
+		modelPath, modelName = path, urlBase(path)
+	}
+	m := &Model{
+		NN:         nn,
+		modelShape: modelShape{InputW: inW, InputH: inH, ClassCount: classes},
+		ModelPath:  modelPath,
+		ModelName:  modelName,
+		OutputAct:  describeLayers(nn)[len(nn.Layers)-1].Activation,
+		GPU:        nn.WebGPUNative,
+	}
+	m.OutputIsProb = m.OutputAct == "softmax"
+	if !m.OutputIsProb && len(warm) > 0 {
+		// A second, mid-gray probe keeps one lucky vector from deciding.
+		if out, err := warmupOutput(nn, inW, inH, 0.5); err == nil && looksLikeProbs(append(warm, out)...) {
+			m.OutputIsProb = true
+			log.Printf("%s: output layer is %q but its outputs are already probabilities (non-negative, sum to 1); softmax won't be applied again.", m.ModelName, m.OutputAct)
+		}
+	}
+	if m.GPU && cpuShare > 0 {
+		cpu, err := rebuildNetwork(nn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: CPU replica for -cpu-share: %w", m.ModelName, err)
+		}
+		m.CPU = cpu
+		log.Printf("%s: CPU replica serves %.0f%% of forwards (-cpu-share).", m.ModelName, 100*cpuShare)
+	}
+	if !m.GPU && batchWorkers > 1 {
+		pool, err := batchReplicas(nn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: replicas for -batch-workers: %w", m.ModelName, err)
+		}
+		m.batchPool = pool
+		log.Printf("%s: batches run on %d CPU workers (-batch-workers).", m.ModelName, batchWorkers)
+	}
+	m.logCoverage()
+	m.logWeightStats()
+	return m, nil
+}
+
+// readModel reads (or, for an http(s) URL, downloads) and parses a model
+// file without touching the GPU.
+func readModel(path string) (*parsedBundle, error) {
+	var data []byte
+	var err error
+	if isModelURL(path) {
+		data, err = fetchModel(path)
+	} else {
+		data, err = os.ReadFile(filepath.Clean(path))
+	}
 	if err != nil {
-		return nil, 0, 0, 0, fmt.Errorf("LoadNamedNetworkFromJSONFile: %w", err)
+		return nil, err
+	}
+	return parseParagonModel(data)
+}
+
+// modelDigest is the hex SHA-256 of a network's JSON.
+func modelDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseParagonModel parses a network's JSON into a float32 network and its
+// shapes. Quantized int8/uint8 networks are dequantized here.
+func parseParagonModel(data []byte) (*parsedBundle, error) {
+	if err := checkModelVersion(data); err != nil {
+		return nil, err
+	}
+	quant, err := readQuant(data)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := paragon.LoadNamedNetworkFromJSONString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("LoadNamedNetworkFromJSONString: %w", err)
 	}
 	tmp, ok := loaded.(*paragon.Network[float32])
 	if !ok {
-		return nil, 0, 0, 0, fmt.Errorf("model is not float32: %T", loaded)
+		if quant == nil {
+			return nil, fmt.Errorf("model is not float32: %T", loaded)
+		}
+		if tmp, err = dequantize(loaded, quant); err != nil {
+			return nil, err
+		}
 	}
 
-	// Derive shapes/activations from the loaded net’s layers
+	if len(tmp.Layers) < 2 {
+		return nil, fmt.Errorf("model has %d layers; need an input and an output layer", len(tmp.Layers))
+	}
+	nn, err := rebuildNetwork(tmp)
+	if err != nil {
+		return nil, err
+	}
+	b := &parsedBundle{nn: nn, inW: nn.Layers[0].Width, inH: nn.Layers[0].Height, sha: modelDigest(data), quant: quant}
+	if b.inW*b.inH == 0 {
+		return nil, fmt.Errorf("input layer has zero size (%dx%d)", b.inW, b.inH)
+	}
+	last := nn.Layers[len(nn.Layers)-1]
+	b.classes = last.Width * last.Height
+	return b, nil
+}
+
+// rebuildNetwork builds a fresh network with tmp's layout and weights.
+func rebuildNetwork(tmp *paragon.Network[float32]) (*paragon.Network[float32], error) {
+	// Derive shapes/activations/connectivity from the loaded net’s layers
 	shapes := make([]struct{ Width, Height int }, len(tmp.Layers))
 	acts := make([]string, len(tmp.Layers))
-	trains := make([]bool, len(tmp.Layers))
+	fullyConnected := make([]bool, len(tmp.Layers))
 	for i, L := range tmp.Layers {
 		shapes[i] = struct{ Width, Height int }{L.Width, L.Height}
 		a := "linear"
 		if L.Height > 0 && L.Width > 0 && L.Neurons[0][0] != nil {
 			a = L.Neurons[0][0].Activation
 		}
-		acts[i], trains[i] = a, true
+		acts[i], fullyConnected[i] = a, isFullyConnected(tmp, i)
 	}
-	nn, err := paragon.NewNetwork[float32](shapes, acts, trains)
+	nn, err := paragon.NewNetwork[float32](shapes, acts, fullyConnected)
 	if err != nil {
-		return nil, 0, 0, 0, fmt.Errorf("NewNetwork: %w", err)
+		return nil, fmt.Errorf("NewNetwork: %w", err)
 	}
 	state, _ := tmp.MarshalJSONModel()
 	if err := nn.UnmarshalJSONModel(state); err != nil {
-		return nil, 0, 0, 0, fmt.Errorf("UnmarshalJSONModel: %w", err)
+		return nil, fmt.Errorf("UnmarshalJSONModel: %w", err)
 	}
-	inW, inH := shapes[0].Width, shapes[0].Height
-	last := shapes[len(shapes)-1]
-	classes := last.Width * last.Height
-	return nn, inW, inH, classes, nil
+	return nn, nil
 }
 
 // ─────────────────────────────────────────────────────────────
@@ -200,200 +782,755 @@ func loadParagonModel(path string) (*paragon.Network[float32], int, int, int, er
 
 func (s *Server) handleHealth(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
-		"status":   "ok",
-		"uptime_s": time.Since(s.started).Seconds(),
-		"inflight": atomic.LoadInt64(&s.inflight),
-		"gpu":      s.NN.WebGPUNative,
+		"status":     "ok",
+		"uptime_s":   time.Since(s.started).Seconds(),
+		"inflight":   atomic.LoadInt64(&s.inflight),
+		"gpu":        s.primaryModel().GPU,
+		"breaker":    s.breaker.snapshot(),
+		"goroutines": runtime.NumGoroutine(),
 	})
 }
 
 func (s *Server) handleConfig(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"input":     []int{s.InputW, s.InputH},
-		"classes":   s.ClassCount,
-		"gpu":       s.NN.WebGPUNative,
-		"model":     s.ModelName,
-		"modelPath": s.ModelPath,
-		"startedAt": s.started.UTC().Format(time.RFC3339Nano),
-	})
+	m := s.primaryModel()
+	out := fiber.Map{
+		"input":            []int{s.InputW, s.InputH},
+		"classes":          s.ClassCount,
+		"embedding_dim":    m.embeddingDim(),
+		"labels":           s.Labels,
+		"locales":          m.localeList(),
+		"preset":           s.preset,
+		"preprocess":       s.pre,
+		"model_preprocess": s.modelPre,
+		"input_expr":       inputExpr.String(),
+		"input_mode":       s.inputMode(),
+		"deterministic":    cpuOnly,
+		"output_is_prob":   m.OutputIsProb,
+		"softmax_default":  softmaxDefault,
+		"softmaxes":        m.softmaxes(nil),
+		"paragon":          paragonVersion(),
+		"gpu":              m.GPU,
+		"gpu_coverage":     m.coverage(),
+		"model":            m.ModelName,
+		"models":           s.modelOrder,
+		"model_path":       m.ModelPath,
+		"model_sha256":     m.SHA256,
+		"quantization":     m.Quant,
+		"max_body_bytes":   maxBodyBytes,
+		"max_batch":        s.maxBatch,
+		"max_blast":        maxBlast,
+		"started_at":       apiTime(s.started),
+	}
+	if !wantsCamel(c) {
+		// The names /config had before -json-case, kept for its existing
+		// clients; camel output has them already.
+		out["modelPath"], out["startedAt"] = out["model_path"], out["started_at"]
+	}
+	return c.JSON(out)
 }
 
 type inferReq struct {
-	Input []float64   `json:"input"` // flattened w*h in [0..1]
-	Image [][]float64 `json:"image"` // h×w
+	Input      flatInput `json:"input"` // flattened w*h in [0..1]
+	Image      imageRows `json:"image"` // h×w
+	Width      int       `json:"width"` // with height: input is a width×height image, row-major
+	Height     int       `json:"height"`
+	AutoOrient bool      `json:"auto_orient"`    // accept a w×h image and transpose it
+	Pad        bool      `json:"pad"`            // centre a smaller image on a model-size canvas
+	PadFill    float64   `json:"pad_fill"`       // canvas value for pad, in [0,1] pixel space
+	Model      string    `json:"model"`          // registered model name (default: active)
+	Ensemble   bool      `json:"ensemble"`       // average all registered models
+	Dedup      bool      `json:"dedup"`          // share a forward with identical in-flight inputs
+	TopK       int       `json:"top_k"`          // return k best classes instead of probs
+	GroupBy    string    `json:"group_by"`       // label metadata key to rank groups by
+	Order      string    `json:"order"`          // probs as index (default) or score_desc pairs
+	MinConf    float64   `json:"min_confidence"` // below this softmaxed top score, answer "unknown"
+	Temp       float64   `json:"temperature"`    // softmax(logits / T) recalibration; 0 = off
+	Softmax    *bool     `json:"softmax"`        // softmax the raw outputs; unset = -softmax-default
+	EchoInput  bool      `json:"echo_input"`     // return the preprocessed matrix as input_used
+	Baseline   []float64 `json:"baseline_probs"` // answer with the classes that moved from these instead of probs
+	DiffDelta  float64   `json:"diff_delta"`     // with baseline_probs: the change a class must exceed
+	ProbeLayer *int      `json:"probe_layer"`    // also return this layer's activations, by index
+	LayerDevs  bool      `json:"layer_devices"`  // also return where each layer ran for this forward
+	NoCache    bool      `json:"no_cache"`       // always run a fresh forward (benchmarks)
+	Priority   string    `json:"priority"`       // high | normal (default) | low
 }
 type inferResp struct {
-	TopIndex  int       `json:"top_index"`
-	TopScore  float64   `json:"top_score"`
-	Probs     []float64 `json:"probs"`
-	UsedGPU   bool      `json:"used_gpu"`
-	LatencyMs float64   `json:"latency_ms"`
-	QueuedMs  float64   `json:"queued_ms"`
-	InFlight  int64     `json:"inflight"`
-	When      time.Time `json:"when"`
+	TopIndex       int     `json:"top_index"`
+	TopLabel       string  `json:"top_label,omitempty"`
+	TopScore       float64 `json:"top_score"`
+	Probs          any     `json:"probs,omitempty"` // []float64, or []classScore with order=score_desc
+	UsedGPU        bool    `json:"used_gpu"`
+	Model          string  `json:"model"`
+	StreamID       int     `json:"stream_id"`                  // GPU slot that ran the forward
+	CorpusIndex    *int    `json:"corpus_index,omitempty"`     // /blast with -blast-corpus: the input used
+	DeadlineMs     int64   `json:"deadline_ms,omitempty"`      // -timeout, as in X-Deadline-Ms
+	QueueTimeoutMs int64   `json:"queue_timeout_ms,omitempty"` // -queue-timeout, as in X-Queue-Timeout-Ms
+	LatencyMs      float64 `json:"latency_ms"`
+	QueuedMs       float64 `json:"queued_ms"`
+	InFlight       int64   `json:"inflight"`
+	When           apiTime `json:"when"`
+	Error          string  `json:"error,omitempty"` // set on /blast entries that never ran
+
+	TopK      []classScore    `json:"top_k,omitempty"`
+	TopGroups []groupScore    `json:"top_groups,omitempty"`     // with group_by
+	Ensemble  []memberPred    `json:"ensemble,omitempty"`       // per-model top-1 when ensembling
+	Shared    bool            `json:"shared,omitempty"`         // result came from an identical in-flight forward
+	Temp      float64         `json:"temperature,omitempty"`    // probs were recalibrated at this temperature
+	Softmax   bool            `json:"softmax,omitempty"`        // probs are softmax of the raw outputs
+	Warning   string          `json:"warning,omitempty"`        // a request option was adjusted (top_k clamp, -max-probs)
+	InputUsed [][]float64     `json:"input_used,omitempty"`     // h×w as fed to the model, with echo_input
+	Diff      *probsDiff      `json:"diff,omitempty"`           // with baseline_probs, in place of probs
+	Clamped   bool            `json:"clamped,omitempty"`        // clamp mode changed input values outside [0,1]
+	ClampedN  int             `json:"clamped_values,omitempty"` // how many
+	Probe     *layerProbe     `json:"probe,omitempty"`          // with probe_layer
+	LayerDevs []layerCoverage `json:"layer_devices,omitempty"`  // with layer_devices, one per model layer
+}
+
+// parseBody decodes the request body into out. An empty body gets its own
+// 400 naming the fields to send (want), rather than the decoder's "unexpected
+// end of JSON input" or a bare 422 when no Content-Type came with it.
+func parseBody(c *fiber.Ctx, out any, want string) error {
+	if len(bytes.TrimSpace(c.Body())) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "request body required, provide "+want)
+	}
+	if err := c.BodyParser(out); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return nil
 }
 
 func (s *Server) handleInfer(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
 	var req inferReq
-	if err := c.BodyParser(&req); err != nil {
+	if err := parseBody(c, &req, "'input' or 'image'"); err != nil {
+		return err
+	}
+	img, clamped, err := s.normalizeClamped(req)
+	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
-	img, err := s.normalizeInput(req)
+	return s.inferImage(c, req, img, clamped)
+}
+
+// inferImage is /infer from the model lookup on, for an input already
+// normalized to the model's h×w, of which clamped values were clamped.
+func (s *Server) inferImage(c *fiber.Ctx, req inferReq, img [][]float64, clamped int) error {
+	m, err := s.lookupModel(req.Model)
+	if err != nil {
+		return err
+	}
+	if req.Ensemble {
+		if !s.models.holdsAll() {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf(
+				"ensemble runs all %d -models entries on each request, but -model-cache keeps only %d loaded",
+				len(s.modelOrder)-1, s.models.limit))
+		}
+		err = s.allowEnsemble(c)
+	} else {
+		err = s.allowModel(c, m)
+	}
+	if err != nil {
+		return err
+	}
+	warn, err := s.capTopK(&req.TopK, m.ClassCount)
+	if err != nil {
+		return err
+	}
+	if err := m.checkGroupBy(req.GroupBy); err != nil {
+		return err
+	}
+	if err := checkOrder(req.Order); err != nil {
+		return err
+	}
+	if err := checkMinConfidence(req.MinConf); err != nil {
+		return err
+	}
+	if req.Temp < 0 || math.IsNaN(req.Temp) || math.IsInf(req.Temp, 0) {
+		return fiber.NewError(fiber.StatusBadRequest, "temperature must be > 0 (0 or omitted = off)")
+	}
+	if err := m.checkBaseline(req.Baseline, req.DiffDelta); err != nil {
+		return err
+	}
+	if err := m.checkProbe(req.ProbeLayer); err != nil {
+		return err
+	}
+	if req.ProbeLayer != nil && req.Ensemble {
+		return fiber.NewError(fiber.StatusBadRequest, "probe_layer reads one model's layer; it can't be combined with ensemble")
+	}
+	if req.LayerDevs && req.Ensemble {
+		return fiber.NewError(fiber.StatusBadRequest, "layer_devices describes one model's layers; it can't be combined with ensemble")
+	}
+	prio, err := parsePriority(req.Priority)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	startQ := time.Now()
-	s.sem <- struct{}{}
-	qDelay := time.Since(startQ)
+	trackModel(c, m.ModelName)
+	forwards := 1
+	if req.Ensemble {
+		forwards = len(s.modelOrder)
+	}
+	ctx := c.UserContext()
+	slot, qDelay, err := s.acquireN(ctx, prio, s.lanes.infer, forwards)
+	if err != nil {
+		return acquireError(err)
+	}
 	atomic.AddInt64(&s.inflight, 1)
 	defer func() {
-		<-s.sem
+		s.releaseIn(slot, s.lanes.infer)
 		atomic.AddInt64(&s.inflight, -1)
 	}()
 
 	start := time.Now()
-	s.gpuMu.Lock()
-	s.NN.Forward(img)
-	out := s.NN.ExtractOutput() // []float64
-	s.gpuMu.Unlock()
+	var (
+		out     []float64
+		usedGPU bool
+		members []memberPred
+		shared  bool
+		probe   *layerProbe
+	)
+	modelName := m.ModelName
+	if req.Ensemble {
+		out, members, usedGPU, err = s.forwardEnsemble(ctx, img)
+		modelName = "ensemble"
+	} else if req.ProbeLayer != nil {
+		out, probe, err = s.forwardProbe(ctx, m, img, *req.ProbeLayer)
+	} else if req.Dedup && !noCache(c, req.NoCache) {
+		out, usedGPU, shared, err = s.forwardDedup(ctx, m, img)
+	} else {
+		out, usedGPU, err = s.forwardCtx(ctx, m, img) // []float64
+	}
+	if err != nil {
+		return forwardError(err)
+	}
+	lat := time.Since(start)
+	s.stats.observe(lat)
+	s.stats.observeSlot(slot)
+	s.sampler.maybe(m, img, out)
+	s.audit.record(m, img, out)
+	norm := out // ensemble and temperature output are already probabilities
+	softmaxed := false
+	if req.Temp > 0 {
+		out = scaleTemperature(out, req.Ensemble || m.OutputIsProb, req.Temp)
+		norm = out
+	} else if !req.Ensemble {
+		out, norm, softmaxed = m.present(out, req.Softmax)
+	}
 
 	idx := argmax64(out)
-	return c.JSON(inferResp{
+	var probs any
+	var diff *probsDiff
+	topK := m.topK(out, req.TopK)
+	switch {
+	case req.Baseline != nil:
+		diff = m.diffProbs(out, req.Baseline, req.DiffDelta)
+	case topK == nil:
+		probs = m.ordered(out, req.Order)
+	}
+	var groups []groupScore
+	if req.GroupBy != "" {
+		groups = m.topGroups(norm, req.GroupBy, req.TopK)
+	}
+	resp := inferResp{
 		TopIndex:  idx,
+		TopLabel:  m.label(idx),
 		TopScore:  out[idx],
-		Probs:     out,
-		UsedGPU:   s.NN.WebGPUNative,
-		LatencyMs: durMs(time.Since(start)),
+		TopK:      topK,
+		TopGroups: groups,
+		Probs:     probs,
+		UsedGPU:   usedGPU,
+		Model:     modelName,
+		Ensemble:  members,
+		Shared:    shared,
+		Temp:      req.Temp,
+		Softmax:   softmaxed,
+		Diff:      diff,
+		Probe:     probe,
+		Clamped:   clamped > 0,
+		ClampedN:  clamped,
+		StreamID:  slot,
+		LatencyMs: durMs(lat),
 		QueuedMs:  durMs(qDelay),
 		InFlight:  atomic.LoadInt64(&s.inflight),
-		When:      time.Now(),
-	})
+		When:      apiTime(time.Now()),
+	}
+	resp.Warning = warn
+	resp.DeadlineMs, resp.QueueTimeoutMs = s.reqTimeout.Milliseconds(), s.queueTimeout.Milliseconds()
+	if unsure(norm, req.MinConf) {
+		resp.TopIndex, resp.TopLabel = -1, unknownLabel // top_k/probs keep the raw ranking
+	}
+	if req.EchoInput {
+		resp.InputUsed = img
+	}
+	if req.LayerDevs {
+		resp.LayerDevs = m.layerDevices(usedGPU)
+	}
+	if names := m.requestLabels(c); names != nil {
+		relabel(names, resp.TopIndex, &resp.TopLabel)
+		relabelTopK(names, resp.TopK)
+		relabelOrdered(names, resp.Probs)
+		if diff != nil {
+			for i := range diff.Changed {
+				relabel(names, diff.Changed[i].Index, &diff.Changed[i].Label)
+			}
+		}
+	}
+	return c.JSON(resp)
 }
 
 type batchReq struct {
-	Batch  [][]float64   `json:"batch"`  // N × (w*h)
-	Images [][][]float64 `json:"images"` // N × h × w
+	Batch   [][]float64   `json:"batch"`          // N × (w*h)
+	Images  [][][]float64 `json:"images"`         // N × h × w
+	TopK    int           `json:"top_k"`          // return k best classes instead of probs
+	Ks      []int         `json:"ks"`             // per-sample top_k instead, one per sample
+	GroupBy string        `json:"group_by"`       // label metadata key to rank groups by
+	Order   string        `json:"order"`          // probs as index (default) or score_desc pairs
+	MinConf float64       `json:"min_confidence"` // per sample, as in /infer
+	Softmax *bool         `json:"softmax"`        // as in /infer
+	Stream  bool          `json:"stream"`         // NDJSON, one line per sample as it completes
+	Partial bool          `json:"partial"`        // run the valid samples despite invalid ones (207)
+
+	Priority string `json:"priority"` // high | normal (default) | low
+
+	warning string // set by the handler when top_k was clamped or filled in
 }
 type batchResp struct {
-	TopIndices []int       `json:"top_indices"`
-	TopScores  []float64   `json:"top_scores"`
-	Probs      [][]float64 `json:"probs"`
-	UsedGPU    bool        `json:"used_gpu"`
-	LatencyMs  float64     `json:"latency_ms"`
-	N          int         `json:"n"`
+	TopIndices []int          `json:"top_indices"`
+	TopLabels  []string       `json:"top_labels,omitempty"`
+	TopScores  []float64      `json:"top_scores"`
+	TopK       [][]classScore `json:"top_k,omitempty"`
+	TopGroups  [][]groupScore `json:"top_groups,omitempty"`
+	Probs      any            `json:"probs,omitempty"` // [][]float64, or [][]classScore with order=score_desc
+	UsedGPU    bool           `json:"used_gpu"`
+	Model      string         `json:"model"`
+	StreamID   int            `json:"stream_id"`
+	LatencyMs  float64        `json:"latency_ms"`
+	N          int            `json:"n"`
+	Softmax    bool           `json:"softmax,omitempty"` // as in /infer
+	Indices    []int          `json:"indices,omitempty"` // with partial: request index of each result
+	Errors     []sampleError  `json:"errors,omitempty"`  // with partial: the samples skipped
+	Warning    string         `json:"warning,omitempty"` // a request option was adjusted (top_k clamp, -max-probs)
+}
+
+// sampleError is a validation failure of one batch sample.
+type sampleError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
 }
 
 func (s *Server) handleInferBatch(c *fiber.Ctx) error {
 	var req batchReq
-	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	if err := parseBody(c, &req, "'batch' or 'images'"); err != nil {
+		return err
+	}
+	offers := []string{fiber.MIMEApplicationJSON, mimeNDJSON}
+	if req.Stream {
+		offers = offers[1:]
 	}
-	var imgs [][][]float64
+	mime, err := negotiate(c, offers...)
+	if err != nil {
+		return err
+	}
+	req.Stream = mime == mimeNDJSON
+	// Validate every sample so a client can fix them all in one go.
+	var (
+		imgs    [][][]float64
+		idxs    []int
+		invalid []sampleError
+	)
+	keep := func(i int, img [][]float64, err error) {
+		if err != nil {
+			invalid = append(invalid, sampleError{Index: i, Error: err.Error()})
+			return
+		}
+		imgs, idxs = append(imgs, img), append(idxs, i)
+	}
+	total := max(len(req.Images), len(req.Batch))
+	if total > s.maxBatch {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("batch of %d exceeds -max-batch %d", total, s.maxBatch))
+	}
+	m := s.activeModel() // preprocessed for and forwarded on the same model
 	switch {
 	case len(req.Images) > 0:
-		imgs = req.Images
+		for i, raw := range req.Images {
+			img, err := s.normalizeInput(inferReq{Image: raw, Model: m.ModelName})
+			keep(i, img, err)
+		}
 	case len(req.Batch) > 0:
-		for _, flat := range req.Batch {
-			img, err := s.reshape(flat)
-			if err != nil {
-				return fiber.NewError(fiber.StatusBadRequest, err.Error())
-			}
-			imgs = append(imgs, img)
+		for i, flat := range req.Batch {
+			img, err := s.normalizeInput(inferReq{Input: flat, Model: m.ModelName})
+			keep(i, img, err)
 		}
 	default:
 		return fiber.NewError(fiber.StatusBadRequest, "provide 'images' or 'batch'")
 	}
+	if len(invalid) > 0 && (!req.Partial || len(imgs) == 0) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  fmt.Sprintf("%d of %d samples are invalid", len(invalid), total),
+			"errors": invalid,
+		})
+	}
+	if len(invalid) == 0 {
+		idxs = nil // results line up with the request
+	}
 
-	s.sem <- struct{}{}
-	defer func() { <-s.sem }()
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	if req.Ks != nil {
+		if req.warning, err = s.checkKs(req, total, m.ClassCount); err != nil {
+			return err
+		}
+		if idxs != nil {
+			ks := make([]int, len(idxs))
+			for i, at := range idxs {
+				ks[i] = req.Ks[at]
+			}
+			req.Ks = ks // aligned to imgs from here on
+		}
+	} else if req.warning, err = s.capTopK(&req.TopK, m.ClassCount); err != nil {
+		return err
+	}
+	if err := m.checkGroupBy(req.GroupBy); err != nil {
+		return err
+	}
+	if err := checkOrder(req.Order); err != nil {
+		return err
+	}
+	if err := checkMinConfidence(req.MinConf); err != nil {
+		return err
+	}
+	prio, err := parsePriority(req.Priority)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	slot, _, err := s.acquireN(c.UserContext(), prio, nil, len(imgs))
+	if err != nil {
+		return acquireError(err)
+	}
+	s.stats.observeSlot(slot)
+	if len(invalid) > 0 {
+		c.Status(fiber.StatusMultiStatus)
+	}
+	if req.Stream {
+		return s.streamBatch(c, m, imgs, idxs, invalid, slot, req)
+	}
+	defer s.release(slot)
+
+	resp, err := s.runBatch(c.UserContext(), m, imgs, slot, req)
+	if err != nil {
+		return err
+	}
+	resp.Indices, resp.Errors, resp.Warning = idxs, invalid, req.warning
+	if names := m.requestLabels(c); names != nil {
+		for i, idx := range resp.TopIndices {
+			relabel(names, idx, &resp.TopLabels[i])
+		}
+		for _, ks := range resp.TopK {
+			relabelTopK(names, ks)
+		}
+		if ranked, ok := resp.Probs.([][]classScore); ok {
+			for _, ks := range ranked {
+				relabelTopK(names, ks)
+			}
+		}
+	}
+	return c.JSON(resp)
+}
+
+// runBatch forwards imgs on the slot the caller holds, one after another
+// or, for a CPU model with -batch-workers, in parallel.
+// Only req's output options (top_k, group_by, order, min_confidence,
+// softmax) are used.
+func (s *Server) runBatch(ctx context.Context, m *Model, imgs [][][]float64, slot int, req batchReq) (batchResp, error) {
 	start := time.Now()
 
-	s.gpuMu.Lock()
 	topIdx := make([]int, len(imgs))
+	var topK [][]classScore
+	if req.TopK > 0 || req.Ks != nil {
+		topK = make([][]classScore, len(imgs))
+	}
+	var groups [][]groupScore
+	if req.GroupBy != "" {
+		groups = make([][]groupScore, len(imgs))
+	}
+	var topLabels []string
+	if len(m.Labels) > 0 || req.MinConf > 0 {
+		topLabels = make([]string, len(imgs))
+	}
 	topScores := make([]float64, len(imgs))
 	probs := make([][]float64, len(imgs))
-	for i := range imgs {
-		s.NN.Forward(imgs[i])
-		out := s.NN.ExtractOutput()
+	var ranked [][]classScore
+	if topK == nil && req.Order == orderScore {
+		ranked = make([][]classScore, len(imgs))
+	}
+	outs, allGPU, err := s.batchForwards(ctx, m, imgs)
+	if err != nil {
+		return batchResp{}, forwardError(err)
+	}
+	softmaxed := m.softmaxes(req.Softmax)
+	for i, out := range outs {
+		out, norm, _ := m.present(out, req.Softmax)
 		idx := argmax64(out)
 		topIdx[i], topScores[i], probs[i] = idx, out[idx], out
+		if topK != nil {
+			topK[i] = m.topK(out, req.topKAt(i))
+		}
+		if ranked != nil {
+			ranked[i] = m.topK(out, len(out))
+		}
+		if groups != nil {
+			groups[i] = m.topGroups(norm, req.GroupBy, req.topKAt(i))
+		}
+		if topLabels != nil {
+			topLabels[i] = m.label(idx)
+		}
+		if unsure(norm, req.MinConf) {
+			topIdx[i], topLabels[i] = -1, unknownLabel
+		}
+	}
+	var probsOut any = probs
+	switch {
+	case topK != nil:
+		probsOut = nil
+	case ranked != nil:
+		probsOut = ranked
 	}
-	s.gpuMu.Unlock()
 
-	return c.JSON(batchResp{
+	return batchResp{
 		TopIndices: topIdx,
+		TopLabels:  topLabels,
 		TopScores:  topScores,
-		Probs:      probs,
-		UsedGPU:    s.NN.WebGPUNative,
+		TopK:       topK,
+		TopGroups:  groups,
+		Probs:      probsOut,
+		UsedGPU:    allGPU,
+		Model:      m.ModelName,
+		StreamID:   slot,
 		LatencyMs:  durMs(time.Since(start)),
 		N:          len(imgs),
-	})
+		Softmax:    softmaxed,
+	}, nil
 }
 
+// Request limits, also reported by /config so clients can size requests.
+const (
+	maxBodyBytes = fiber.DefaultBodyLimit // buffered JSON bodies; /infer/upload and /score stream
+	maxBlast     = 2000                   // /blast n
+)
+
 type blastReq struct {
-	N     int       `json:"n"`
-	Input []float64 `json:"input"`
+	N       int       `json:"n"`
+	Input   flatInput `json:"input"`
+	Dedup   bool      `json:"dedup"`    // identical inputs share in-flight forwards
+	Ramp    *rampOpts `json:"ramp"`     // pace submissions instead of firing all at once
+	NoCache bool      `json:"no_cache"` // ignore dedup; every entry runs its own forward
+	Corpus  bool      `json:"corpus"`   // cycle through -blast-corpus (default when input is omitted)
+	Softmax *bool     `json:"softmax"`  // as in /infer
+
+	Priority string `json:"priority"` // high | normal (default) | low
 }
 type blastResp struct {
 	Count    int         `json:"count"`
 	Results  []inferResp `json:"results"`
 	TotalMs  float64     `json:"total_ms"`
 	Parallel int         `json:"parallel"`
+	Phases   []rampPhase `json:"phases,omitempty"`  // with ramp: latency by submission phase
+	Warning  string      `json:"warning,omitempty"` // entries carry top_k instead of probs (-max-probs)
 }
 
 func (s *Server) handleBlast(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
 	var req blastReq
-	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	if err := parseBody(c, &req, "'n' and 'input'"); err != nil {
+		return err
+	}
+	if req.N <= 0 || req.N > maxBlast {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("n must be 1..%d", maxBlast))
 	}
-	if req.N <= 0 || req.N > 2000 {
-		return fiber.NewError(fiber.StatusBadRequest, "n must be 1..2000")
+	if req.Ramp != nil {
+		if err := req.Ramp.validate(req.N); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	if err := s.allowModel(c, s.activeModel()); err != nil {
+		return err
+	}
+	// Entry i runs inputs[i % len]: the one request input, or the corpus.
+	var inputs [][][]float64
+	useCorpus := req.Corpus || (len(req.Input) == 0 && s.corpus != nil)
+	if useCorpus {
+		if s.corpus == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "corpus requested but the server has no -blast-corpus")
+		}
+		inputs = s.corpus
+	} else {
+		img, err := s.reshape(req.Input)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		inputs = [][][]float64{img}
 	}
-	img, err := s.reshape(req.Input)
+	req.Dedup = req.Dedup && !noCache(c, req.NoCache)
+	prio, err := parsePriority(req.Priority)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
+	var (
+		topK int // blast has no top_k of its own; only -max-probs sets it
+		warn string
+	)
+	if n := s.activeModel().ClassCount; s.maxProbs > 0 && n > s.maxProbs {
+		topK = s.maxProbs
+		warn = fmt.Sprintf("results carry the top %d of %d classes instead of probs (-max-probs)", topK, n)
+	}
 
+	ctx := c.UserContext()
+	if req.Ramp != nil {
+		ctx = jobContext(c) // -timeout applies per paced submission
+	}
 	start := time.Now()
 	results := make([]inferResp, req.N)
+	sent := make([]time.Duration, req.N)
+	type blastJob struct {
+		ix     int
+		at     time.Time // submitted
+		ctx    context.Context
+		cancel context.CancelFunc
+	}
+	// A fixed pool drains the submissions, so n doesn't set the goroutine
+	// count. Buffered for all n: a busy pool never holds up ramp pacing.
+	jobs := make(chan blastJob, req.N)
+	run := func(j blastJob) {
+		defer j.cancel()
+		ix := j.ix
+		slot, _, err := s.acquireIn(j.ctx, prio, s.lanes.blast)
+		qDelay := time.Since(j.at) // waiting for a worker, then for a slot
+		if err != nil {
+			results[ix] = inferResp{TopIndex: -1, StreamID: -1, QueuedMs: durMs(qDelay), Error: err.Error(), When: apiTime(time.Now())}
+			return
+		}
+		atomic.AddInt64(&s.inflight, 1)
+		defer func() {
+			s.releaseIn(slot, s.lanes.blast)
+			atomic.AddInt64(&s.inflight, -1)
+		}()
+
+		img := inputs[ix%len(inputs)]
+		m := s.activeModel()
+		t1 := time.Now()
+		var (
+			out     []float64
+			usedGPU bool
+			shared  bool
+		)
+		if req.Dedup {
+			out, usedGPU, shared, err = s.forwardDedup(j.ctx, m, img)
+		} else {
+			out, usedGPU, err = s.forwardCtx(j.ctx, m, img)
+		}
+		if err != nil {
+			results[ix] = inferResp{TopIndex: -1, StreamID: slot, QueuedMs: durMs(qDelay), Error: err.Error(), When: apiTime(time.Now())}
+			return
+		}
+		s.stats.observe(time.Since(t1))
+		s.stats.observeSlot(slot)
+		s.sampler.maybe(m, img, out)
+		s.audit.record(m, img, out)
+
+		out, _, softmaxed := m.present(out, req.Softmax)
+		idx := argmax64(out)
+		var probs any = out
+		ks := m.topK(out, topK)
+		if ks != nil {
+			probs = nil
+		}
+		results[ix] = inferResp{
+			TopIndex:  idx,
+			TopLabel:  m.label(idx),
+			TopScore:  out[idx],
+			TopK:      ks,
+			Probs:     probs,
+			Softmax:   softmaxed,
+			UsedGPU:   usedGPU,
+			Model:     m.ModelName,
+			Shared:    shared,
+			StreamID:  slot,
+			LatencyMs: durMs(time.Since(j.at)),
+			QueuedMs:  durMs(qDelay),
+			InFlight:  atomic.LoadInt64(&s.inflight),
+			When:      apiTime(time.Now()),
+		}
+		if useCorpus {
+			ci := ix % len(inputs)
+			results[ix].CorpusIndex = &ci
+		}
+	}
+	workers := s.blastWorkers(req.N)
 	var wg sync.WaitGroup
-	for i := 0; i < req.N; i++ {
+	for range workers {
 		wg.Add(1)
-		go func(ix int) {
+		go func() {
 			defer wg.Done()
-			t0 := time.Now()
-			s.sem <- struct{}{}
-			qDelay := time.Since(t0)
-			atomic.AddInt64(&s.inflight, 1)
-
-			s.gpuMu.Lock()
-			s.NN.Forward(img)
-			out := s.NN.ExtractOutput()
-			s.gpuMu.Unlock()
-
-			idx := argmax64(out)
-			results[ix] = inferResp{
-				TopIndex:  idx,
-				TopScore:  out[idx],
-				Probs:     out,
-				UsedGPU:   s.NN.WebGPUNative,
-				LatencyMs: durMs(time.Since(t0)),
-				QueuedMs:  durMs(qDelay),
-				InFlight:  atomic.LoadInt64(&s.inflight),
-				When:      time.Now(),
+			for j := range jobs {
+				run(j)
 			}
-			<-s.sem
-			atomic.AddInt64(&s.inflight, -1)
-		}(i)
+		}()
 	}
+	for i := 0; i < req.N; i++ {
+		if req.Ramp != nil {
+			select {
+			case <-time.After(time.Until(start.Add(req.Ramp.offset(i)))):
+			case <-ctx.Done(): // canceled: the rest fail fast in acquire
+			}
+		}
+		j := blastJob{ix: i, at: time.Now(), ctx: ctx, cancel: func() {}}
+		if req.Ramp != nil {
+			j.ctx, j.cancel = s.withDeadline(ctx)
+		}
+		sent[i] = time.Since(start)
+		jobs <- j
+	}
+	close(jobs)
 	wg.Wait()
-	return c.JSON(blastResp{
+	if names := s.activeModel().requestLabels(c); names != nil {
+		for i := range results {
+			relabel(names, results[i].TopIndex, &results[i].TopLabel)
+		}
+	}
+	parallel := s.lanes.blast.size(s.slots.size)
+	if parallel == 0 { // -maxgpu 0: as many at once as there are workers
+		parallel = workers
+	}
+	resp := blastResp{
 		Count:    req.N,
 		Results:  results,
 		TotalMs:  durMs(time.Since(start)),
-		Parallel: cap(s.sem),
-	})
+		Parallel: parallel,
+		Warning:  warn,
+	}
+	if req.Ramp != nil {
+		resp.Phases = rampPhases(sent, results)
+	}
+	return c.JSON(resp)
+}
+
+// blastWorkerFactor is the /blast pool size per blast slot: enough that a
+// freed slot always has a worker queued for it.
+const blastWorkerFactor = 2
+
+// blastWorkers sizes the /blast worker pool for n submissions.
+func (s *Server) blastWorkers(n int) int {
+	w := s.lanes.blast.size(s.slots.size) * blastWorkerFactor
+	if w == 0 { // -maxgpu 0: no slot limit to size by
+		w = runtime.GOMAXPROCS(0) * blastWorkerFactor
+	}
+	return min(w, n)
 }
 
 // NEW: save a full client session JSON to disk
@@ -402,19 +1539,23 @@ func (s *Server) handleSaveSession(c *fiber.Ctx) error {
 	if err := json.Unmarshal(c.Body(), &raw); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid JSON")
 	}
-	if err := os.MkdirAll("./data/sessions", 0o755); err != nil {
+	if err := os.MkdirAll(sessionDir, 0o755); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
 	ts := time.Now().UTC().Format("20060102T150405.000000000Z")
-	fname := fmt.Sprintf("./data/sessions/%s_%s.json", ts, safeBase(s.ModelName))
-	if err := os.WriteFile(fname, c.Body(), 0o644); err != nil {
+	name := s.primaryModel().ModelName
+	// Replay checks the session name with safeBase, so the model part leaves
+	// room within safeBaseMax for the timestamp and a -N suffix.
+	fname, err := writeNew(fmt.Sprintf("%s/%s_%s", sessionDir, ts, safeName(name, sessionModelMax)), ".json", c.Body())
+	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
 	return c.JSON(fiber.Map{
 		"saved":   true,
+		"name":    strings.TrimSuffix(filepath.Base(fname), ".json"),
 		"path":    fname,
 		"bytes":   len(c.Body()),
-		"model":   s.ModelName,
+		"model":   name,
 		"created": ts,
 	})
 }
@@ -423,6 +1564,61 @@ func (s *Server) handleSaveSession(c *fiber.Ctx) error {
 // Helpers
 // ─────────────────────────────────────────────────────────────
 
+// activeModel returns the model requests should run on right now: the
+// fallback while the degrader has switched over, otherwise the primary.
+func (s *Server) activeModel() *Model {
+	if s.degrade != nil && s.degrade.active.Load() {
+		return s.degrade.fallback
+	}
+	return s.primaryModel()
+}
+
+// forward runs one sample through m under the GPU lock and reports whether
+// the GPU produced it. GPU failures feed the breaker and the sample is
+// recomputed on CPU; with the breaker open and fail-fast set, it errors.
+// An empty output is an error, so callers can index out[argmax64(out)].
+func (s *Server) forward(m *Model, img [][]float64) ([]float64, bool, error) {
+	if m.CPU != nil && s.routeToCPU() {
+		return s.replicaForward(m, img)
+	}
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	usedGPU := false
+	if m.GPU && m.NN.WebGPUNative { // off once a reload has retired m
+		if s.breaker.allow() {
+			t0 := time.Now()
+			err := gpuForward(m.NN, img)
+			if err == nil {
+				s.stats.observeGPU(time.Since(t0))
+				s.breaker.success()
+				usedGPU = true
+			} else {
+				if isGPUOOM(err) {
+					atomic.AddInt64(&s.stats.gpuOOM, 1)
+					log.Printf("GPU out of memory on %s; forward runs on CPU: %v", m.ModelName, err)
+				}
+				s.breaker.failure(err)
+			}
+		} else if s.breaker.failFast {
+			return nil, false, errBreakerOpen
+		}
+	}
+	if usedGPU {
+		atomic.AddInt64(&s.stats.gpuForwards, 1)
+	} else {
+		cpuForward(m.NN, img)
+		atomic.AddInt64(&s.stats.cpuForwards, 1)
+		if m.GPU {
+			atomic.AddInt64(&s.stats.gpuFallbacks, 1)
+		}
+	}
+	out := m.NN.ExtractOutput()
+	if len(out) == 0 {
+		return nil, usedGPU, errNoOutput
+	}
+	return out, usedGPU, nil
+}
+
 func makeImage(w, h int, val float64) [][]float64 {
 	img := make([][]float64, h)
 	for r := 0; r < h; r++ {
@@ -435,40 +1631,156 @@ func makeImage(w, h int, val float64) [][]float64 {
 	return img
 }
 
+// reshape turns a flat input into an image with the active model's
+// preprocessing.
 func (s *Server) reshape(flat []float64) ([][]float64, error) {
+	img, _, err := s.reshapeWith(s.preFor(""), flat)
+	return img, err
+}
+
+// reshapeWith is reshape with pre's preprocessing; it also returns how many
+// values were clamped.
+func (s *Server) reshapeWith(pre *preprocess, flat []float64) ([][]float64, int, error) {
+	flat = pre.adaptFlat(flat, s.InputW, s.InputH)
 	if len(flat) != s.InputW*s.InputH {
-		return nil, fmt.Errorf("flattened input must be length %d (got %d)", s.InputW*s.InputH, len(flat))
+		return nil, 0, fmt.Errorf("flattened input must be length %d, %d wide × %d high (got %d)",
+			s.InputW*s.InputH, s.InputW, s.InputH, len(flat))
 	}
 	img := make([][]float64, s.InputH)
 	for r := 0; r < s.InputH; r++ {
 		row := make([]float64, s.InputW)
-		for c := 0; c < s.InputW; c++ {
-			v := flat[r*s.InputW+c]
-			if v < 0 {
-				v = 0
-			}
-			if v > 1 {
-				v = 1
-			}
-			row[c] = v
-		}
+		copy(row, flat[r*s.InputW:(r+1)*s.InputW])
 		img[r] = row
 	}
-	return img, nil
+	if err := s.checkRange(img); err != nil {
+		return nil, 0, err
+	}
+	img, clamped := pre.apply(img)
+	return img, clamped, nil
 }
 
+// shapeHint suggests what n values might be: the factorizations closest to
+// square, each a width × height an /infer request can declare so the input
+// is padded or resized like an image.
+func shapeHint(n int) string {
+	var pairs []string
+	for h := int(math.Sqrt(float64(n))); h >= 2 && len(pairs) < 3; h-- {
+		if n%h == 0 {
+			pairs = append(pairs, fmt.Sprintf("%d×%d", n/h, h))
+		}
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "; if it is a " + strings.Join(pairs, " or ") + " (w×h) image, send \"width\" and \"height\" with \"pad\":true or a resizing -preset"
+}
+
+// normalizeInput validates a request's input and applies the
+// preprocessing of the model it names.
 func (s *Server) normalizeInput(req inferReq) ([][]float64, error) {
+	img, _, err := s.normalizeClamped(req)
+	return img, err
+}
+
+// normalizeClamped is normalizeInput that also returns how many input
+// values were clamped to [0,1].
+func (s *Server) normalizeClamped(req inferReq) ([][]float64, int, error) {
+	pre := s.preFor(req.Model)
 	switch {
 	case len(req.Image) > 0:
-		if len(req.Image) != s.InputH || len(req.Image[0]) != s.InputW {
-			return nil, fmt.Errorf("image must be %dx%d (h×w)", s.InputH, s.InputW)
+		if err := checkRows(req.Image, s.InputW); err != nil {
+			return nil, 0, err
+		}
+		if req.AutoOrient && s.InputW != s.InputH &&
+			len(req.Image) == s.InputW && rowsHaveLen(req.Image, s.InputH) {
+			log.Printf("auto_orient: transposing %dx%d image to %dx%d (h×w)", s.InputW, s.InputH, s.InputH, s.InputW)
+			if err := s.checkRange(req.Image); err != nil {
+				return nil, 0, err
+			}
+			img, clamped := pre.apply(transpose(req.Image))
+			return img, clamped, nil
+		}
+		if err := s.checkRange(req.Image); err != nil {
+			return nil, 0, err
+		}
+		img := req.Image
+		if req.Pad {
+			img = pre.pad(img, s.InputW, s.InputH, req.PadFill)
+		}
+		img = pre.adaptImage(img, s.InputW, s.InputH)
+		if len(img) != s.InputH || len(img[0]) != s.InputW {
+			return nil, 0, fmt.Errorf("image must be %dx%d (h×w)", s.InputH, s.InputW)
+		}
+		img, clamped := pre.apply(copyImage(img))
+		return img, clamped, nil
+	case len(req.Input) > 0 && (req.Width > 0 || req.Height > 0):
+		// Declared dims make the flat input an image, so pad, resize
+		// and auto_orient apply to it as they would to "image".
+		if req.Width <= 0 || req.Height <= 0 || req.Width*req.Height != len(req.Input) {
+			return nil, 0, fmt.Errorf("width %d × height %d doesn't match the %d input values", req.Width, req.Height, len(req.Input))
+		}
+		img := make([][]float64, req.Height)
+		for r := range img {
+			img[r] = req.Input[r*req.Width : (r+1)*req.Width]
 		}
-		return req.Image, nil
+		req.Input, req.Image = nil, img
+		return s.normalizeClamped(req)
 	case len(req.Input) > 0:
-		return s.reshape(req.Input)
+		img, clamped, err := s.reshapeWith(pre, req.Input)
+		if err != nil && len(req.Input) != s.InputW*s.InputH {
+			return nil, 0, fmt.Errorf("%w%s", err, shapeHint(len(req.Input)))
+		}
+		return img, clamped, err
 	default:
-		return nil, fmt.Errorf("provide 'image' or flattened 'input'")
+		return nil, 0, fmt.Errorf("provide 'image' or flattened 'input'")
+	}
+}
+
+func copyImage(m [][]float64) [][]float64 {
+	out := make([][]float64, len(m))
+	for r, row := range m {
+		out[r] = append([]float64(nil), row...)
+	}
+	return out
+}
+
+// checkRows fails on a ragged image and names the first row out of line:
+// the first that isn't w long when some rows are, otherwise the first that
+// differs from row 0 (an image pad or resize would have fixed up).
+func checkRows(m [][]float64, w int) error {
+	if rowsHaveLen(m, len(m[0])) {
+		return nil
+	}
+	want, from := w, ""
+	if !slices.ContainsFunc(m, func(row []float64) bool { return len(row) == w }) {
+		want, from = len(m[0]), " like row 0"
+	}
+	for r, row := range m {
+		if len(row) != want {
+			return fmt.Errorf("image row %d has %d values, want %d%s: rows must all be the same length", r, len(row), want, from)
+		}
+	}
+	return nil
+}
+
+func rowsHaveLen(m [][]float64, n int) bool {
+	for _, row := range m {
+		if len(row) != n {
+			return false
+		}
 	}
+	return true
+}
+
+func transpose(m [][]float64) [][]float64 {
+	out := make([][]float64, len(m[0]))
+	for c := range out {
+		out[c] = make([]float64, len(m))
+		for r := range m {
+			out[c][r] = m[r][c]
+		}
+	}
+	return out
 }
 
 func argmax64(v []float64) int {
@@ -489,17 +1801,32 @@ func durMs(d time.Duration) float64 {
 	return float64(d.Microseconds()) / 1000.0
 }
 
-func safeBase(s string) string {
-	b := filepath.Base(s)
-	forbidden := []rune{'/', '\\', ':', '*', '?', '"', '<', '>', '|', ' '}
-	runes := []rune(b)
-	for i, r := range runes {
-		for _, f := range forbidden {
-			if r == f {
-				runes[i] = '_'
-				break
-			}
+// safeBaseMax bounds safeBase's result in bytes, well under the usual
+// 255-byte file name limit so a prefix and extension still fit.
+const safeBaseMax = 128
+
+// safeBase reduces s to a file name that is safe on any filesystem: its
+// last path element in NFC (so a name typed on macOS and on Linux maps to
+// the same file), without control or invisible format characters (bidi
+// overrides can make "exe.json" display as "nosj.exe") or invalid UTF-8,
+// with separators, shell/Windows-reserved characters and spaces turned into
+// '_', cut to safeBaseMax bytes on a rune boundary. It is idempotent, so a
+// name it produced passes the name == safeBase(name) check unchanged.
+func safeBase(s string) string { return safeName(s, safeBaseMax) }
+
+func safeName(s string, maxBytes int) string {
+	var b strings.Builder
+	for _, r := range norm.NFC.String(filepath.Base(s)) {
+		switch {
+		case r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			continue
+		case strings.ContainsRune(`/\:*?"<>| `, r):
+			r = '_'
+		}
+		if b.Len()+utf8.RuneLen(r) > maxBytes {
+			break
 		}
+		b.WriteRune(r)
 	}
-	return string(runes)
+	return b.String()
 }
@@ -0,0 +1,1167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	fiberrecover "github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Test helpers
+// ─────────────────────────────────────────────────────────────
+
+func TestMain(m *testing.M) {
+	// Tests run on the CPU, where outputs are bit-stable and no WebGPU
+	// adapter is needed.
+	cpuOnly = true
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testModelJSON builds a Paragon JSON network: a w×h input layer, a dense
+// relu hidden layer and a dense output layer of classes cells with act.
+// Weights and biases are fixed functions of their position, so the same
+// arguments always give the same network.
+func testModelJSON(w, h, hidden, classes int, act string) []byte {
+	type conn struct {
+		Layer int     `json:"layer"`
+		X     int     `json:"x"`
+		Y     int     `json:"y"`
+		W     float64 `json:"w"`
+	}
+	type neuron struct {
+		B  float64 `json:"b"`
+		A  string  `json:"a"`
+		In []conn  `json:"in"`
+	}
+	type layer struct {
+		W int        `json:"w"`
+		H int        `json:"h"`
+		N [][]neuron `json:"n"`
+	}
+	dense := func(from, width int, prevW, prevH int, act string, seed float64) layer {
+		row := make([]neuron, width)
+		for x := range row {
+			in := make([]conn, 0, prevW*prevH)
+			for y := 0; y < prevH; y++ {
+				for px := 0; px < prevW; px++ {
+					k := float64(x*prevW*prevH + y*prevW + px)
+					in = append(in, conn{Layer: from, X: px, Y: y, W: math.Round(math.Sin(seed+k*0.7)*1e4) / 1e5})
+				}
+			}
+			row[x] = neuron{B: math.Round(math.Cos(seed+float64(x))*1e3) / 1e4, A: act, In: in}
+		}
+		return layer{W: width, H: 1, N: [][]neuron{row}}
+	}
+	input := layer{W: w, H: h, N: make([][]neuron, h)}
+	for y := range input.N {
+		input.N[y] = make([]neuron, w)
+		for x := range input.N[y] {
+			input.N[y][x] = neuron{A: "linear", In: []conn{}}
+		}
+	}
+	net := map[string]any{
+		"type": "float32",
+		"layers": []layer{
+			input,
+			dense(0, hidden, w, h, "relu", 1),
+			dense(1, classes, hidden, 1, act, 2),
+		},
+	}
+	b, err := json.Marshal(net)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// writeTestModel writes testModelJSON's network to a temp file and returns
+// its path.
+func writeTestModel(t testing.TB, name string, w, h, hidden, classes int, act string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, testModelJSON(w, h, hidden, classes, act), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestServer loads path as the primary model, and extra as -models, and
+// wires up a Server and its inference and admin routes the way main does
+// with default flags.
+func newTestServer(t testing.TB, path string, extra ...string) (*Server, *fiber.App) {
+	t.Helper()
+	m, err := loadModel(path, nil)
+	if err != nil {
+		t.Fatalf("load %s: %v", path, err)
+	}
+	const maxGPU = 4
+	s := &Server{
+		modelShape:  m.modelShape,
+		source:      modelSource{path: path},
+		slots:       newScheduler(maxGPU),
+		reloadDrain: time.Second,
+		maxBatch:    256,
+		maxTopK:     100,
+		maxProbs:    100,
+
+		maxUploadPixels: 2048 * 2048,
+		breaker: &breaker{
+			threshold: 5,
+			window:    30 * time.Second,
+			cooldown:  30 * time.Second,
+		},
+		started: time.Now(),
+		quit:    make(chan struct{}),
+	}
+	s.lanes.infer, s.lanes.blast = newLane("infer", 0), newLane("blast", 0)
+	s.primary.Store(m)
+	s.stats.slotUse = make([]int64, maxGPU)
+	s.models = newModelCache(0, s.retire, &s.gpuMu)
+	if s.modelOrder, err = loadRegistry(m, strings.Join(extra, ","), s.models); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.pre.validate(m.InputW); err != nil {
+		t.Fatal(err)
+	}
+	if s.modelPre, err = loadModelPreprocess(m, s.models); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Use(fiberrecover.New())
+	app.Post("/infer", s.requireKey, s.track, s.handleInfer)
+	app.Post("/infer/embed", s.requireKey, s.track, s.handleEmbed)
+	app.Post("/infer-batch", s.requireKey, s.track, s.handleInferBatch)
+	app.Post("/blast", s.requireKey, s.track, s.handleBlast)
+	app.Post("/explain", s.requireKey, s.track, s.handleExplain)
+	app.Post("/verify", s.requireKey, s.track, s.handleVerify)
+	app.Post("/save-session", s.requireKey, s.handleSaveSession)
+	app.Get("/sessions/:name", s.requireKey, s.handleGetSession)
+	app.Get("/model/stats", s.requireKey, s.handleModelStats)
+	app.Post("/admin/reload", s.handleReload)
+	return s, app
+}
+
+// post sends body as JSON to path and decodes the JSON reply into a map.
+func post(t *testing.T, app *fiber.App, path, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		out = map[string]any{"raw": string(raw)}
+	}
+	return resp.StatusCode, out
+}
+
+// testInput is a w×h input as a JSON array of values in [0,1).
+func testInput(w, h int) string {
+	vals := make([]float64, w*h)
+	for i := range vals {
+		vals[i] = float64(i%7) / 7
+	}
+	b, _ := json.Marshal(vals)
+	return string(b)
+}
+
+// ─────────────────────────────────────────────────────────────
+// Forwards
+// ─────────────────────────────────────────────────────────────
+
+// A network whose ExtractOutput comes back empty must fail the request
+// cleanly instead of indexing into nothing.
+func TestForwardNoOutput(t *testing.T) {
+	// Linear output: Paragon's own softmax would index the empty layer.
+	s, app := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "linear"))
+	nn := s.primaryModel().NN
+	nn.Layers[nn.OutputLayer].Width = 0 // ExtractOutput now returns []
+
+	if _, _, err := s.forward(s.primaryModel(), makeImage(4, 4, 0.5)); err != errNoOutput {
+		t.Fatalf("forward: got err %v, want errNoOutput", err)
+	}
+	input := testInput(4, 4)
+	for _, tc := range []struct{ path, body string }{
+		{"/infer", `{"input":` + input + `}`},
+		{"/infer/embed", `{"input":` + input + `}`},
+		{"/infer-batch", `{"batch":[` + input + `,` + input + `]}`},
+	} {
+		code, body := post(t, app, tc.path, tc.body)
+		if code != fiber.StatusInternalServerError {
+			t.Errorf("%s: status %d %v, want 500", tc.path, code, body)
+		}
+		if raw, _ := body["raw"].(string); !strings.Contains(raw, errNoOutput.Error()) {
+			t.Errorf("%s: body %v, want %q", tc.path, body, errNoOutput)
+		}
+	}
+
+	// /blast reports errors per entry rather than failing the whole run.
+	code, body := post(t, app, "/blast", `{"n":4,"input":`+input+`}`)
+	results, _ := body["results"].([]any)
+	if code != fiber.StatusOK || len(results) != 4 {
+		t.Fatalf("/blast: status %d %v, want 200 with 4 results", code, body)
+	}
+	for i, r := range results {
+		r := r.(map[string]any)
+		if r["error"] != errNoOutput.Error() || r["top_index"] != -1.0 {
+			t.Errorf("/blast result %d: %v, want error %q and top_index -1", i, r, errNoOutput)
+		}
+	}
+}
+
+// An output layer with no cells must fail the load, not mount a model
+// that serves argmax over zero classes. Paragon refuses such a layer in
+// JSON; a network that reaches mountModel with one anyway (classes == 0)
+// is refused there once its warmup forward comes back empty.
+func TestLoadZeroSizeOutput(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		w, h int
+	}{
+		{"zero width", 0, 1},
+		{"zero height", 3, 0},
+		{"zero both", 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var net map[string]any
+			if err := json.Unmarshal(testModelJSON(4, 4, 6, 3, "linear"), &net); err != nil {
+				t.Fatal(err)
+			}
+			layers := net["layers"].([]any)
+			rows := make([][]any, tc.h)
+			for y := range rows {
+				rows[y] = []any{}
+			}
+			layers[len(layers)-1] = map[string]any{"w": tc.w, "h": tc.h, "n": rows}
+			data, _ := json.Marshal(net)
+			path := filepath.Join(t.TempDir(), "zero.json")
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if m, err := loadModel(path, nil); err == nil {
+				t.Fatalf("loaded a model with %d classes, want an error", m.ClassCount)
+			} else if !strings.Contains(err.Error(), "zero") {
+				t.Errorf("error %q doesn't name the zero-size layer", err)
+			}
+		})
+	}
+
+	t.Run("mount", func(t *testing.T) {
+		b, err := parseParagonModel(testModelJSON(4, 4, 6, 3, "linear"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.nn.Layers[b.nn.OutputLayer].Width = 0
+		if m, err := mountModel(b.nn, b.inW, b.inH, 0, "zero.json", nil); err == nil {
+			t.Fatalf("mounted a model with %d classes, want an error", m.ClassCount)
+		} else if !strings.Contains(err.Error(), "output layer has zero size") {
+			t.Errorf("error %q, want the zero-size output error", err)
+		}
+	})
+}
+
+// goldenInput covers the clamp: 1.5 and -0.5 are out of range.
+const goldenInput = `[0,0.1,0.2,0.3,0.4,0.5,0.6,0.7,0.8,0.9,1,1.5,-0.5,0.25,0.75,0.125]`
+
+// With -deterministic the whole pipeline, from input normalization through
+// the forward to softmax, must give the same bits on every run and across
+// refactors. The expected values were recorded from this pipeline; a change
+// here is a behavior change, not noise.
+func TestDeterministicOutputs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pre  preprocess
+		want []float64
+	}{
+		{"clamp", preprocess{Channels: 1},
+			[]float64{0.34016644954681396, 0.3227957487106323, 0.3370378017425537}},
+		{"invert and standardize", preprocess{Channels: 1, Invert: true, Mean: []float64{0.5}, Std: []float64{0.25}},
+			[]float64{0.34093335270881653, 0.33407676219940186, 0.32498985528945923}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, app := newTestServer(t, writeTestModel(t, "golden.json", 4, 4, 6, 3, "softmax"))
+			s.pre = tc.pre
+			for run := 0; run < 3; run++ {
+				code, body := post(t, app, "/infer", `{"input":`+goldenInput+`}`)
+				if code != fiber.StatusOK {
+					t.Fatalf("status %d: %v", code, body)
+				}
+				probs, _ := body["probs"].([]any)
+				if len(probs) != len(tc.want) {
+					t.Fatalf("run %d: probs %v, want %v", run, probs, tc.want)
+				}
+				for i, p := range probs {
+					if math.Float64bits(p.(float64)) != math.Float64bits(tc.want[i]) {
+						t.Errorf("run %d: probs[%d] = %v, want exactly %v", run, i, p, tc.want[i])
+					}
+				}
+				if body["clamped_values"] != 2.0 {
+					t.Errorf("run %d: clamped_values %v, want 2", run, body["clamped_values"])
+				}
+			}
+		})
+	}
+
+	t.Run("raw forward", func(t *testing.T) {
+		s, _ := newTestServer(t, writeTestModel(t, "raw.json", 4, 4, 6, 3, "linear"))
+		var flat []float64
+		if err := json.Unmarshal([]byte(goldenInput), &flat); err != nil {
+			t.Fatal(err)
+		}
+		img, err := s.normalizeInput(inferReq{Input: flat})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []float64{-0.04812490567564964, -0.10054026544094086, -0.057364895939826965}
+		for run := 0; run < 3; run++ {
+			out, usedGPU, err := s.forward(s.primaryModel(), img)
+			if err != nil || usedGPU {
+				t.Fatalf("forward: usedGPU %v, err %v", usedGPU, err)
+			}
+			if !slices.Equal(out, want) {
+				t.Errorf("run %d: %v, want exactly %v", run, out, want)
+			}
+		}
+	})
+}
+
+// ─────────────────────────────────────────────────────────────
+// Sessions
+// ─────────────────────────────────────────────────────────────
+
+// Concurrent saves must each land in their own file, even when they share
+// a name: none may overwrite another.
+func TestConcurrentSaves(t *testing.T) {
+	const n = 200
+
+	t.Run("save-session", func(t *testing.T) {
+		dir := sessionDir
+		sessionDir = t.TempDir()
+		t.Cleanup(func() { sessionDir = dir })
+		_, app := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+
+		names := make([]string, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				code, body := post(t, app, "/save-session", fmt.Sprintf(`{"i":%d}`, i))
+				if code != fiber.StatusOK {
+					t.Errorf("save %d: status %d %v", i, code, body)
+					return
+				}
+				names[i], _ = body["name"].(string)
+			}()
+		}
+		wg.Wait()
+		for i, name := range names {
+			req := httptest.NewRequest("GET", "/sessions/"+name, nil)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if want := fmt.Sprintf(`{"i":%d}`, i); string(raw) != want {
+				t.Errorf("session %q: %q, want %q", name, raw, want)
+			}
+		}
+		if files, _ := os.ReadDir(sessionDir); len(files) != n {
+			t.Errorf("%d files in %s, want %d", len(files), sessionDir, n)
+		}
+	})
+
+	t.Run("same name", func(t *testing.T) {
+		stem := filepath.Join(t.TempDir(), "session")
+		paths := make([]string, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p, err := writeNew(stem, ".json", []byte(strconv.Itoa(i)))
+				if err != nil {
+					t.Errorf("write %d: %v", i, err)
+				}
+				paths[i] = p
+			}()
+		}
+		wg.Wait()
+		seen := make(map[string]bool)
+		for i, p := range paths {
+			if seen[p] {
+				t.Fatalf("%s written twice", p)
+			}
+			seen[p] = true
+			if data, err := os.ReadFile(p); err != nil || string(data) != strconv.Itoa(i) {
+				t.Errorf("%s: %q, %v; want %d", p, data, err, i)
+			}
+		}
+	})
+}
+
+// ─────────────────────────────────────────────────────────────
+// GPU slots
+// ─────────────────────────────────────────────────────────────
+
+func TestCheckGPUFlags(t *testing.T) {
+	for _, tc := range []struct {
+		maxGPU, infer, blast int
+		wantErr              string
+	}{
+		{-1, 0, 0, "-maxgpu must be 0"},
+		{0, 0, 0, ""},
+		{0, 8, 8, ""}, // no global cap to stay under
+		{0, -1, 0, "-maxgpu-infer"},
+		{1, 0, 0, ""},
+		{1, 1, 1, ""},
+		{1, 2, 0, "-maxgpu-infer"},
+		{4, 4, 5, "-maxgpu-blast"},
+		{4, 0, -1, "-maxgpu-blast"},
+	} {
+		err := checkGPUFlags(tc.maxGPU, tc.infer, tc.blast)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("checkGPUFlags(%d, %d, %d): %v, want ok", tc.maxGPU, tc.infer, tc.blast, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("checkGPUFlags(%d, %d, %d): %v, want an error about %s", tc.maxGPU, tc.infer, tc.blast, err, tc.wantErr)
+		}
+	}
+}
+
+// -maxgpu 0 means no limit: nobody waits for a slot, however many are
+// held. Any other size queues the request past it.
+func TestSchedulerBounds(t *testing.T) {
+	s, _ := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+	s.queueTimeout = 20 * time.Millisecond
+	for _, size := range []int{0, 1, 4} {
+		s.slots = newScheduler(size)
+		held := 16 // with no limit, more than any sized case holds
+		if size > 0 {
+			held = size
+		}
+		var slots []int
+		for i := range held {
+			slot, waited, err := s.acquire(t.Context(), prioNormal)
+			if err != nil || waited >= s.queueTimeout {
+				t.Fatalf("maxgpu %d: acquire %d: slot %d, waited %s, err %v", size, i, slot, waited, err)
+			}
+			if slices.Contains(slots, slot) {
+				t.Fatalf("maxgpu %d: slot %d handed out twice", size, slot)
+			}
+			slots = append(slots, slot)
+		}
+		_, _, err := s.acquire(t.Context(), prioNormal)
+		if size == 0 && err != nil {
+			t.Errorf("maxgpu 0: acquire with %d held: %v, want a slot", held, err)
+		}
+		if size > 0 && !errors.Is(err, errQueueTimeout) {
+			t.Errorf("maxgpu %d: acquire with all held: %v, want a queue timeout", size, err)
+		}
+	}
+}
+
+// ─────────────────────────────────────────────────────────────
+// Model selection
+// ─────────────────────────────────────────────────────────────
+
+// A request's "model" is only ever a registered name: a path or URL, even
+// to a loadable model, is a 404 and is never opened or fetched.
+func TestModelNameCantLoadPath(t *testing.T) {
+	dir := t.TempDir()
+	primary := writeTestModel(t, "primary.json", 4, 4, 6, 3, "softmax")
+	extra := writeTestModel(t, "extra.json", 4, 4, 6, 3, "softmax")
+	evil := filepath.Join(dir, "evil.json")
+	if err := os.WriteFile(evil, testModelJSON(4, 4, 6, 3, "softmax"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var fetched atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Add(1)
+		w.Write(testModelJSON(4, 4, 6, 3, "softmax"))
+	}))
+	defer srv.Close()
+
+	s, app := newTestServer(t, primary, extra)
+	wd, _ := os.Getwd()
+	rel, err := filepath.Rel(wd, evil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := testInput(4, 4)
+	for _, name := range []string{
+		evil,             // absolute path to a loadable model
+		rel,              // the same, relative
+		"./evil.json",    // a name-like relative path
+		"../evil.json",   // traversal
+		extra,            // a registered model by path, not name
+		"file://" + evil, // URL forms
+		srv.URL + "/evil.json",
+		"evil.json", // a file name that isn't registered
+	} {
+		for _, path := range []string{"/infer", "/infer/embed"} {
+			body, _ := json.Marshal(map[string]any{"model": name, "input": json.RawMessage(input)})
+			code, resp := post(t, app, path, string(body))
+			if code != fiber.StatusNotFound {
+				t.Errorf("%s with model %q: status %d %v, want 404", path, name, code, resp)
+			}
+		}
+	}
+	if n := fetched.Load(); n != 0 {
+		t.Errorf("model URL fetched %d times", n)
+	}
+	if got := s.models.residentModels(); len(got) != 1 || got[0].ModelName != "extra.json" {
+		names := []string{}
+		for _, m := range got {
+			names = append(names, m.ModelName)
+		}
+		t.Errorf("resident models %v, want only extra.json", names)
+	}
+
+	// The registered names themselves still work.
+	for _, name := range []string{"primary.json", "extra.json"} {
+		code, resp := post(t, app, "/infer", `{"model":"`+name+`","input":`+input+`}`)
+		if code != fiber.StatusOK || resp["model"] != name {
+			t.Errorf("model %q: status %d %v, want 200 from it", name, code, resp)
+		}
+	}
+}
+
+// /model/stats is a GET anyone may send, so it must read only resident
+// models: naming an evicted one mustn't load it back.
+func TestModelStatsResidentOnly(t *testing.T) {
+	s, app := newTestServer(t, writeTestModel(t, "primary.json", 4, 4, 6, 3, "softmax"),
+		writeTestModel(t, "a.json", 4, 4, 5, 3, "softmax"),
+		writeTestModel(t, "b.json", 4, 4, 7, 3, "softmax"))
+	s.models.mu.Lock()
+	s.models.limit = 1
+	evict := s.models.overflowLocked() // a.json, the least recently used
+	s.models.mu.Unlock()
+	s.models.retireAll(evict)
+
+	get := func(query string) int {
+		resp, err := app.Test(httptest.NewRequest("GET", "/model/stats"+query, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for query, want := range map[string]int{
+		"":                    fiber.StatusOK,
+		"?model=primary.json": fiber.StatusOK,
+		"?model=b.json":       fiber.StatusOK,
+		"?model=a.json":       fiber.StatusConflict,
+		"?model=nope.json":    fiber.StatusNotFound,
+	} {
+		if got := get(query); got != want {
+			t.Errorf("/model/stats%s: status %d, want %d", query, got, want)
+		}
+	}
+	if m, _ := s.models.peek("a.json"); m != nil {
+		t.Error("/model/stats loaded the evicted a.json")
+	}
+	s.models.mu.Lock()
+	defer s.models.mu.Unlock()
+	if s.models.hits != 0 || s.models.misses != 0 {
+		t.Errorf("/model/stats counted %d hits and %d misses; it shouldn't touch the cache",
+			s.models.hits, s.models.misses)
+	}
+}
+
+// A dedup leader whose forward panics must still close its flight: the
+// waiters get an error instead of hanging, and the key is free again.
+func TestFlightLeaderPanic(t *testing.T) {
+	var g flightGroup
+	key := hashInput("m.json", makeImage(2, 2, 0))
+	release := make(chan struct{})
+	go func() {
+		defer func() { recover() }()
+		g.do(key, func() ([]float64, bool, error) {
+			<-release
+			panic("bad forward")
+		})
+	}()
+	time.Sleep(20 * time.Millisecond) // the leader holds the flight
+
+	type result struct {
+		shared bool
+		err    error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		_, _, shared, err := g.do(key, func() ([]float64, bool, error) {
+			return nil, false, errors.New("ran its own forward")
+		})
+		waiter <- result{shared, err}
+	}()
+	time.Sleep(20 * time.Millisecond) // the waiter is parked on the flight
+	close(release)
+
+	select {
+	case r := <-waiter:
+		if !r.shared || r.err != errSharedPanic {
+			t.Errorf("waiter: shared %v, err %v; want the leader's flight and errSharedPanic", r.shared, r.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter still blocked after the leader panicked")
+	}
+	out, _, shared, err := g.do(key, func() ([]float64, bool, error) { return []float64{1}, false, nil })
+	if shared || err != nil || len(out) != 1 {
+		t.Errorf("after the panic: shared %v, %v, %v; want a fresh forward", shared, out, err)
+	}
+}
+
+// A JSON-RPC batch is capped at -max-batch calls like /infer-batch; over
+// it, none of the calls run.
+func TestRPCBatchCap(t *testing.T) {
+	s, app := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+	app.Post("/rpc", s.handleRPC)
+	s.maxBatch = 4
+
+	calls := func(n int) string {
+		call := `{"jsonrpc":"2.0","id":1,"method":"infer","params":{"input":` + testInput(4, 4) + `}}`
+		return "[" + strings.TrimSuffix(strings.Repeat(call+",", n), ",") + "]"
+	}
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(calls(4)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []rpcResponse
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if err != nil || len(out) != 4 || out[0].Error != nil {
+		t.Fatalf("batch of 4: %v %+v", err, out)
+	}
+
+	before := atomic.LoadInt64(&s.stats.cpuForwards)
+	_, body := post(t, app, "/rpc", calls(5))
+	if e, _ := body["error"].(map[string]any); e == nil || e["code"] != float64(rpcInvalidRequest) {
+		t.Errorf("batch of 5 over -max-batch 4: %v, want error %d", body, rpcInvalidRequest)
+	}
+	if n := atomic.LoadInt64(&s.stats.cpuForwards) - before; n != 0 {
+		t.Errorf("the refused batch ran %d forwards", n)
+	}
+}
+
+// syncWeights rolls a failed in-place reload back with weightsOf and
+// setWeights; putting a snapshot back must restore the outputs exactly.
+func TestWeightsRollback(t *testing.T) {
+	parse := func() *paragon.Network[float32] {
+		b, err := parseParagonModel(testModelJSON(4, 4, 6, 3, "linear"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.nn.WebGPUNative = false
+		return b.nn
+	}
+	live, next := parse(), parse()
+	for _, row := range next.Layers[2].Neurons {
+		for _, n := range row {
+			n.Bias += 1
+		}
+	}
+	img := makeImage(4, 4, 0.5)
+	live.Forward(img)
+	want := live.ExtractOutput()
+
+	prev := weightsOf(live)
+	setWeights(live, weightsOf(next))
+	if !sameLayout(live, next) {
+		t.Fatal("setWeights changed the layout")
+	}
+	live.Forward(img)
+	if got := live.ExtractOutput(); slices.Equal(got, want) {
+		t.Fatal("the new weights didn't take")
+	}
+	setWeights(live, prev)
+	live.Forward(img)
+	if got := live.ExtractOutput(); !slices.Equal(got, want) {
+		t.Errorf("after rollback: %v, want %v", got, want)
+	}
+}
+
+// /model/stats reads the weights an in-place reload rewrites under gpuMu,
+// so it must take gpuMu too; go test -race flags it otherwise.
+func TestModelStatsDuringSync(t *testing.T) {
+	path := writeTestModel(t, "primary.json", 4, 4, 6, 3, "linear")
+	s, app := newTestServer(t, path)
+	live := s.primaryModel().NN
+	b, err := parseParagonModel(testModelJSON(4, 4, 6, 3, "linear"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := [2][][][]neuronWeights{weightsOf(live), weightsOf(b.nn)}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			s.gpuMu.Lock()
+			setWeights(live, versions[i%2])
+			s.gpuMu.Unlock()
+		}
+	}()
+	for i := range 20 {
+		resp, err := app.Test(httptest.NewRequest("GET", "/model/stats", nil), -1)
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Errorf("/model/stats %d: %v %v", i, resp, err)
+		}
+	}
+	close(stop)
+	<-done
+}
+
+// /explain and /interpolate preprocess with the named model's sidecar, so
+// they must forward on that model too, not the active one.
+func TestNamedModelPreprocessing(t *testing.T) {
+	extra := writeTestModel(t, "b.json", 4, 4, 5, 3, "softmax")
+	sidecar := strings.TrimSuffix(extra, ".json") + ".preprocess.json"
+	if err := os.WriteFile(sidecar, []byte(`{"invert":true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, app := newTestServer(t, writeTestModel(t, "primary.json", 4, 4, 6, 3, "softmax"), extra)
+	app.Post("/interpolate", s.requireKey, s.track, s.handleInterpolate)
+
+	input := testInput(4, 4)
+	_, infer := post(t, app, "/infer", `{"model":"b.json","input":`+input+`}`)
+	code, explain := post(t, app, "/explain", `{"model":"b.json","input":`+input+`,"patch":2}`)
+	if code != fiber.StatusOK || explain["model"] != "b.json" || explain["top_score"] != infer["top_score"] {
+		t.Errorf("/explain on b.json: status %d, model %v, top_score %v; want b.json and /infer's %v",
+			code, explain["model"], explain["top_score"], infer["top_score"])
+	}
+
+	body := `{"a":{"model":"b.json","input":` + input + `},"b":{"input":` + input + `},"steps":2}`
+	code, interp := post(t, app, "/interpolate", body)
+	steps, _ := interp["steps"].([]any)
+	if code != fiber.StatusOK || interp["model"] != "b.json" || len(steps) != 2 {
+		t.Fatalf("/interpolate on b.json: status %d %v", code, interp)
+	}
+	if got := steps[0].(map[string]any)["top_score"]; got != infer["top_score"] {
+		t.Errorf("/interpolate at t=0: top_score %v, want /infer's %v", got, infer["top_score"])
+	}
+	body = `{"a":{"model":"b.json","input":` + input + `},"b":{"model":"primary.json","input":` + input + `}}`
+	if code, resp := post(t, app, "/interpolate", body); code != fiber.StatusBadRequest {
+		t.Errorf("/interpolate across models: status %d %v, want 400", code, resp)
+	}
+}
+
+// softmax:true must not normalize outputs that load-time detection found
+// to be probabilities already; only -softmax-default force overrides it.
+func TestSoftmaxes(t *testing.T) {
+	logits := &Model{OutputAct: "linear"}
+	detected := &Model{OutputAct: "linear", OutputIsProb: true}
+	layer := &Model{OutputAct: "softmax", OutputIsProb: true}
+	yes, no := true, false
+	for _, tc := range []struct {
+		def  string
+		want *bool
+		m    *Model
+		out  bool
+	}{
+		{softmaxAuto, nil, logits, true},
+		{softmaxAuto, nil, detected, false},
+		{softmaxAuto, &yes, detected, false},
+		{softmaxAuto, &yes, layer, false},
+		{softmaxAuto, &yes, logits, true},
+		{softmaxAuto, &no, logits, false},
+		{softmaxOn, nil, detected, false},
+		{softmaxOn, nil, logits, true},
+		{softmaxOff, nil, logits, false},
+		{softmaxForce, nil, detected, true},
+		{softmaxForce, nil, layer, false},
+		{softmaxForce, &yes, detected, false}, // a request's true is still true
+	} {
+		prev := softmaxDefault
+		softmaxDefault = tc.def
+		got := tc.m.softmaxes(tc.want)
+		softmaxDefault = prev
+		if got != tc.out {
+			t.Errorf("-softmax-default %s, softmax %v, %s (prob %v): %v, want %v",
+				tc.def, tc.want != nil && *tc.want, tc.m.OutputAct, tc.m.OutputIsProb, got, tc.out)
+		}
+	}
+}
+
+// camelKeys converts field names only: keys of dataKeyed maps are data a
+// client sends back, like model names, and must come through unchanged.
+func TestCamelKeys(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{`{"top_index":1,"top_label":"a_b"}`, `{"topIndex":1,"topLabel":"a_b"}`},
+		{`{"by_model":{"mnist_model.json":3},"last_used":"x"}`, `{"byModel":{"mnist_model.json":3},"lastUsed":"x"}`},
+		{`{"keys":[{"by_model":{"a_b.json":1,"c_d.json":2}}]}`, `{"keys":[{"byModel":{"a_b.json":1,"c_d.json":2}}]}`},
+		{`{"model_preprocess":{"cifar_10.json":{"input_mode":"x"}}}`, `{"modelPreprocess":{"cifar_10.json":{"inputMode":"x"}}}`},
+		{`{"queued_by_priority":{"high_lane":0},"max_batch":4}`, `{"queuedByPriority":{"high_lane":0},"maxBatch":4}`},
+		{`{"by_model":{},"model_path":"p"}`, `{"byModel":{},"modelPath":"p"}`},
+		{`{"by_model":null,"model_path":"p"}`, `{"byModel":null,"modelPath":"p"}`},
+		{`{"label":"by_model","some_key":{"x_y":1}}`, `{"label":"by_model","someKey":{"xY":1}}`},
+	} {
+		if got := string(camelKeys([]byte(tc.in))); got != tc.want {
+			t.Errorf("camelKeys(%s)\n  = %s\nwant %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+// /config keeps the modelPath and startedAt keys it had before -json-case
+// in snake mode, next to the snake ones.
+func TestConfigLegacyKeys(t *testing.T) {
+	s, _ := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+	app := fiber.New()
+	app.Use(keyCase)
+	app.Get("/config", s.handleConfig)
+	get := func(query string) map[string]any {
+		resp, err := app.Test(httptest.NewRequest("GET", "/config"+query, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	snake := get("")
+	for _, k := range []string{"modelPath", "startedAt", "model_path", "started_at"} {
+		if snake[k] == nil {
+			t.Errorf("snake /config has no %s", k)
+		}
+	}
+	camel := get("?json_case=camel")
+	if camel["modelPath"] != snake["model_path"] || camel["model_path"] != nil {
+		t.Errorf("camel /config: modelPath %v, model_path %v", camel["modelPath"], camel["model_path"])
+	}
+}
+
+// ─────────────────────────────────────────────────────────────
+// File names
+// ─────────────────────────────────────────────────────────────
+
+func TestSafeBase(t *testing.T) {
+	for _, tc := range []struct{ name, in, want string }{
+		{"plain", "model.json", "model.json"},
+		{"directories", "../../etc/passwd", "passwd"},
+		{"spaces", "my model v2", "my_model_v2"},
+		{"reserved", `a:b*c?d"e<f>g|h\i`, "a_b_c_d_e_f_g_h_i"},
+		{"control", "a\x00b\nc\td\x7fe\u0085f", "abcdef"},
+		{"bidi override", "exe\u202enosj.json", "exenosj.json"},
+		{"zero width", "a\u200bb\ufeffc", "abc"},
+		{"invalid utf-8", "a\xffb\xc3", "ab"},
+		{"nfc", "cafe\u0301", "caf\u00e9"},
+		{"nfc already", "caf\u00e9", "caf\u00e9"},
+		{"non-ascii kept", "模型-δ.json", "模型-δ.json"},
+		{"length", strings.Repeat("a", 200), strings.Repeat("a", safeBaseMax)},
+		{"length on rune boundary", "a" + strings.Repeat("\u00e9", 100), "a" + strings.Repeat("\u00e9", 63)},
+		{"length after nfc", strings.Repeat("e\u0301", 100), strings.Repeat("\u00e9", 64)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := safeBase(tc.in)
+			if got != tc.want {
+				t.Errorf("safeBase(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+			if len(got) > safeBaseMax || !utf8.ValidString(got) {
+				t.Errorf("safeBase(%q) = %q: %d bytes, valid UTF-8 %v", tc.in, got, len(got), utf8.ValidString(got))
+			}
+			if again := safeBase(got); again != got {
+				t.Errorf("safeBase isn't idempotent: %q → %q → %q", tc.in, got, again)
+			}
+		})
+	}
+}
+
+// ─────────────────────────────────────────────────────────────
+// Reload
+// ─────────────────────────────────────────────────────────────
+
+// Reloads swap the primary while blasts are running on it. Each request
+// must finish on the model it started with, never a half-swapped or
+// retired one. Run under go test -race.
+func TestReloadDuringBlast(t *testing.T) {
+	path := writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax")
+	s, app := newTestServer(t, path)
+	s.reloadDrain = 50 * time.Millisecond
+	versions := [][]byte{testModelJSON(4, 4, 6, 3, "softmax"), testModelJSON(4, 4, 6, 3, "linear")}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	input := testInput(4, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				code, body := post(t, app, "/blast", `{"n":8,"input":`+input+`}`)
+				if code != fiber.StatusOK {
+					t.Errorf("/blast: status %d %v", code, body)
+					return
+				}
+				for _, r := range body["results"].([]any) {
+					if r := r.(map[string]any); r["error"] != nil || r["top_index"].(float64) < 0 {
+						t.Errorf("/blast result during reload: %v", r)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i := range 10 {
+		if err := os.WriteFile(path, versions[i%2], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if code, body := post(t, app, "/admin/reload", ""); code != fiber.StatusOK {
+			t.Errorf("reload %d: status %d %v", i, code, body)
+		}
+		resp, err := app.Test(httptest.NewRequest("GET", "/model/stats", nil), -1)
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Errorf("/model/stats after reload %d: %v %v", i, resp, err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if got := s.primaryModel().OutputAct; got != "linear" {
+		t.Errorf("primary output %q after the last reload, want linear", got)
+	}
+}
+
+// ─────────────────────────────────────────────────────────────
+// Input validation
+// ─────────────────────────────────────────────────────────────
+
+// A ragged image is a 400 naming the first row out of line, wherever it
+// is, and never reaches a forward.
+func TestRaggedImage(t *testing.T) {
+	s, app := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+	row := func(n int) []float64 { return make([]float64, n) }
+	for _, tc := range []struct {
+		name    string
+		req     inferReq
+		wantErr string
+	}{
+		{"bad row 0", inferReq{Image: [][]float64{row(2), row(4), row(4), row(4)}},
+			"image row 0 has 2 values, want 4:"},
+		{"bad middle row", inferReq{Image: [][]float64{row(4), row(4), row(3), row(4)}},
+			"image row 2 has 3 values, want 4:"},
+		{"bad last row", inferReq{Image: [][]float64{row(4), row(4), row(4), row(5)}},
+			"image row 3 has 5 values, want 4:"},
+		{"padded, ragged", inferReq{Image: [][]float64{row(2), row(1)}, Pad: true},
+			"image row 1 has 1 values, want 2 like row 0:"},
+		{"padded", inferReq{Image: [][]float64{row(2), row(2), row(2)}, Pad: true}, ""},
+		{"full size", inferReq{Image: [][]float64{row(4), row(4), row(4), row(4)}}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := s.normalizeInput(tc.req)
+			if tc.wantErr == "" {
+				if err != nil || len(img) != 4 || !rowsHaveLen(img, 4) {
+					t.Fatalf("got %v, %v; want a 4×4 image", img, err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+				t.Fatalf("error %v, want %q…", err, tc.wantErr)
+			}
+			body, _ := json.Marshal(tc.req)
+			code, resp := post(t, app, "/infer", string(body))
+			if raw, _ := resp["raw"].(string); code != fiber.StatusBadRequest || !strings.HasPrefix(raw, tc.wantErr) {
+				t.Errorf("/infer: status %d %v, want 400 %q…", code, resp, tc.wantErr)
+			}
+		})
+	}
+}
+
+// ─────────────────────────────────────────────────────────────
+// Deadlines
+// ─────────────────────────────────────────────────────────────
+
+// -timeout runs from the request's arrival and covers its forwards, not
+// just the wait for a slot: a batch whose first forward outlives the
+// deadline stops there with a 504.
+func TestRequestDeadline(t *testing.T) {
+	s, app := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+	s.reqTimeout = 20 * time.Millisecond
+
+	s.gpuMu.Lock() // the first forward waits past the deadline
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		s.gpuMu.Unlock()
+	}()
+	input := testInput(4, 4)
+	req := httptest.NewRequest("POST", "/infer-batch", strings.NewReader(`{"batch":[`+input+`,`+input+`,`+input+`]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusGatewayTimeout || !strings.Contains(string(raw), errDeadline.Error()) {
+		t.Errorf("status %d %q, want 504 %q", resp.StatusCode, raw, errDeadline)
+	}
+	if got := resp.Header.Get("X-Deadline-Ms"); got != "20" {
+		t.Errorf("X-Deadline-Ms %q, want 20", got)
+	}
+	if got := atomic.LoadInt64(&s.stats.cpuForwards); got != 1 {
+		t.Errorf("%d forwards ran, want 1: the rest were past the deadline", got)
+	}
+
+	// Without the hold the same batch fits in the deadline.
+	if code, body := post(t, app, "/infer-batch", `{"batch":[`+input+`,`+input+`,`+input+`]}`); code != fiber.StatusOK {
+		t.Errorf("unhindered batch: status %d %v", code, body)
+	}
+}
+
+// A ramp must submit all n within rampWindowMax: a trickle target_rps
+// with a large n would hold the connection for days.
+func TestRampWindow(t *testing.T) {
+	for _, tc := range []struct {
+		ramp    rampOpts
+		n       int
+		wantErr string
+	}{
+		{rampOpts{Seconds: 10, TargetRPS: 50}, 100, ""},
+		{rampOpts{Seconds: 300, TargetRPS: 10000}, maxBlast, ""},
+		{rampOpts{Seconds: 10, TargetRPS: 1}, 295, ""},      // 5s ramp + 290 at 1/s = 295s
+		{rampOpts{Seconds: 10, TargetRPS: 1}, 350, "limit"}, // ~350s
+		{rampOpts{Seconds: 1, TargetRPS: 0.001}, 2000, "limit"},
+		{rampOpts{Seconds: 0, TargetRPS: 50}, 10, "ramp_seconds"},
+		{rampOpts{Seconds: 10, TargetRPS: 0}, 10, "target_rps"},
+	} {
+		err := tc.ramp.validate(tc.n)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%+v n=%d: %v, want ok", tc.ramp, tc.n, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%+v n=%d: %v, want an error about %s", tc.ramp, tc.n, err, tc.wantErr)
+		}
+	}
+
+	_, app := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+	body := `{"n":2000,"input":` + testInput(4, 4) + `,"ramp":{"ramp_seconds":1,"target_rps":0.001}}`
+	if code, resp := post(t, app, "/blast", body); code != fiber.StatusBadRequest {
+		t.Errorf("trickle ramp: status %d %v, want 400", code, resp)
+	}
+}
+
+// -global-rps charges a token per forward, so a batch pays for each of
+// its samples rather than once for its slot.
+func TestGlobalRPSPerForward(t *testing.T) {
+	s, app := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+	s.throttle = newThrottle(10, 10, 0) // no queueing: short means 429
+
+	input := testInput(4, 4)
+	batch := `{"batch":[` + strings.TrimSuffix(strings.Repeat(input+",", 8), ",") + `]}`
+	if code, body := post(t, app, "/infer-batch", batch); code != fiber.StatusOK {
+		t.Fatalf("first batch of 8: status %d %v", code, body)
+	}
+	if code, body := post(t, app, "/infer-batch", batch); code != fiber.StatusTooManyRequests {
+		t.Errorf("second batch of 8 with 2 tokens left: status %d %v, want 429", code, body)
+	}
+	if code, body := post(t, app, "/infer", `{"input":`+input+`}`); code != fiber.StatusOK {
+		t.Errorf("one /infer with 2 tokens left: status %d %v", code, body)
+	}
+
+	// Bigger than the burst: admitted on a full bucket, the rest is debt.
+	th := newThrottle(10, 10, 0)
+	if err := th.take(context.Background(), time.Time{}, 25); err != nil {
+		t.Fatalf("batch of 25 on a full bucket: %v", err)
+	}
+	if err := th.take(context.Background(), time.Time{}, 1); err != errThrottled {
+		t.Errorf("take after the debt: %v, want errThrottled", err)
+	}
+}
+
+// useBatchWorkers sets -batch-workers for the models a test loads after it.
+func useBatchWorkers(t testing.TB, n int) {
+	prev := batchWorkers
+	batchWorkers = n
+	t.Cleanup(func() { batchWorkers = prev })
+}
+
+// A panic in a -batch-workers forward runs outside Fiber's recover, on a
+// worker goroutine; it must come back as the batch's error, not end the
+// process, and the replica must go back to the pool.
+func TestBatchWorkerPanic(t *testing.T) {
+	useBatchWorkers(t, 2)
+	s, _ := newTestServer(t, writeTestModel(t, "m.json", 4, 4, 6, 3, "softmax"))
+	m := s.primaryModel()
+	if m.batchPool == nil {
+		t.Fatal("CPU model has no batch pool with -batch-workers 2")
+	}
+
+	good := makeImage(4, 4, 0.5)
+	bad := [][]float64{{0.5}} // one row of one pixel: the forward indexes past it
+	_, _, err := s.batchForwards(context.Background(), m, [][][]float64{good, bad, good, good})
+	if err == nil || !strings.Contains(err.Error(), "forward panic") {
+		t.Fatalf("batch with a bad sample: %v, want a forward panic error", err)
+	}
+	if len(m.batchPool) != cap(m.batchPool) {
+		t.Errorf("%d of %d replicas back in the pool", len(m.batchPool), cap(m.batchPool))
+	}
+	outs, _, err := s.batchForwards(context.Background(), m, [][][]float64{good, good, good})
+	if err != nil || len(outs) != 3 {
+		t.Errorf("batch after the panic: %d outputs, %v", len(outs), err)
+	}
+}
+
+// BenchmarkBatchWorkers times a 256-sample batch on an MNIST-sized CPU
+// model, sequential and across workers. The gain depends on GOMAXPROCS,
+// which caps the workers; run with -cpu to compare core counts.
+func BenchmarkBatchWorkers(b *testing.B) {
+	imgs := make([][][]float64, 256)
+	for i := range imgs {
+		imgs[i] = makeImage(28, 28, float64(i%10)/10)
+	}
+	for _, n := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", n), func(b *testing.B) {
+			useBatchWorkers(b, n)
+			s, _ := newTestServer(b, writeTestModel(b, "mnist.json", 28, 28, 64, 10, "softmax"))
+			m := s.primaryModel()
+			b.ResetTimer()
+			for range b.N {
+				if _, _, err := s.batchForwards(context.Background(), m, imgs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
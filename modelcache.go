@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Resident model cache (-model-cache)
+// ─────────────────────────────────────────────────────────────
+
+// modelCache holds the -models registry. Every name is registered at
+// startup, but with a limit only that many stay loaded: the least recently
+// used is retired (GPU buffers released) and dropped once the cap is
+// exceeded, and is loaded from its file again the next time a request
+// names it. The primary lives outside the cache and is always resident.
+type modelCache struct {
+	limit  int // max resident models; 0 keeps every one loaded
+	retire func(*Model)
+	gpu    sync.Locker // the server's gpuMu, held while a reload mounts
+
+	mu       sync.Mutex
+	paths    map[string]string // every registered name → its file
+	resident map[string]*Model
+	lru      []string // resident names, least recently used first
+	hits     int64
+	misses   int64
+	evicted  int64
+
+	loadMu sync.Mutex // one load at a time; hits don't wait on it
+}
+
+func newModelCache(limit int, retire func(*Model), gpu sync.Locker) *modelCache {
+	return &modelCache{
+		limit:    limit,
+		retire:   retire,
+		gpu:      gpu,
+		paths:    map[string]string{},
+		resident: map[string]*Model{},
+	}
+}
+
+// add registers m, loaded from path, as resident.
+func (mc *modelCache) add(path string, m *Model) {
+	mc.mu.Lock()
+	mc.paths[m.ModelName] = path
+	mc.resident[m.ModelName] = m
+	mc.lru = append(mc.lru, m.ModelName)
+	evict := mc.overflowLocked()
+	mc.mu.Unlock()
+	mc.retireAll(evict)
+}
+
+// get returns the named model, loading it again when it was evicted. The
+// reloaded model must still fit primary's shape and takes its labels.
+func (mc *modelCache) get(name string, primary *Model) (*Model, bool, error) {
+	if m, ok, hit := mc.lookup(name); !ok || hit {
+		return m, ok, nil
+	}
+	mc.loadMu.Lock()
+	defer mc.loadMu.Unlock()
+	mc.mu.Lock()
+	m, loaded := mc.resident[name] // by the load we waited on
+	path := mc.paths[name]
+	mc.mu.Unlock()
+	if loaded {
+		return m, true, nil
+	}
+	m, err := loadModel(path, mc.gpu)
+	if err == nil {
+		err = sameShape(primary, m)
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("loading evicted model %s: %w", name, err)
+	}
+	m.Labels, m.LabelMeta = primary.Labels, primary.LabelMeta
+	log.Printf("Model cache: loaded %s.", name)
+	mc.add(path, m)
+	return m, true, nil
+}
+
+// lookup finds name among the resident models, counting a hit or a miss
+// for registered names.
+func (mc *modelCache) lookup(name string) (m *Model, registered, hit bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if _, ok := mc.paths[name]; !ok {
+		return nil, false, false
+	}
+	if m, ok := mc.resident[name]; ok {
+		mc.hits++
+		mc.touchLocked(name)
+		return m, true, true
+	}
+	mc.misses++
+	return nil, true, false
+}
+
+// peek is lookup without the side effects: it neither counts nor
+// refreshes name's place in the LRU. m is nil for an evicted model.
+func (mc *modelCache) peek(name string) (m *Model, registered bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	_, registered = mc.paths[name]
+	return mc.resident[name], registered
+}
+
+func (mc *modelCache) touchLocked(name string) {
+	if i := slices.Index(mc.lru, name); i >= 0 {
+		mc.lru = append(slices.Delete(mc.lru, i, i+1), name)
+	}
+}
+
+// overflowLocked removes the least recently used models beyond the limit
+// and returns them for retiring outside mc.mu.
+func (mc *modelCache) overflowLocked() []*Model {
+	var out []*Model
+	for mc.limit > 0 && len(mc.lru) > mc.limit {
+		name := mc.lru[0]
+		mc.lru = mc.lru[1:]
+		out = append(out, mc.resident[name])
+		delete(mc.resident, name)
+		mc.evicted++
+	}
+	return out
+}
+
+// retireAll releases evicted models. A request still holding one finishes
+// on its CPU weights.
+func (mc *modelCache) retireAll(ms []*Model) {
+	for _, m := range ms {
+		mc.retire(m)
+		log.Printf("Model cache: evicted %s.", m.ModelName)
+	}
+}
+
+// holdsAll reports whether every registered model fits under the limit,
+// which an ensemble needs: it runs all of them on each request.
+func (mc *modelCache) holdsAll() bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.limit == 0 || mc.limit >= len(mc.paths)
+}
+
+// registeredPaths maps every registered name to its file.
+func (mc *modelCache) registeredPaths() map[string]string {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return maps.Clone(mc.paths)
+}
+
+// residentModels lists the loaded models.
+func (mc *modelCache) residentModels() []*Model {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	out := make([]*Model, 0, len(mc.lru))
+	for _, name := range mc.lru {
+		out = append(out, mc.resident[name])
+	}
+	return out
+}
+
+// snapshot is the /stats view; resident counts the primary too.
+func (mc *modelCache) snapshot() fiber.Map {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return fiber.Map{
+		"resident":   len(mc.resident) + 1,
+		"registered": len(mc.paths) + 1,
+		"limit":      mc.limit,
+		"hits":       mc.hits,
+		"misses":     mc.misses,
+		"evictions":  mc.evicted,
+	}
+}
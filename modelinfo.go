@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Model introspection
+// ─────────────────────────────────────────────────────────────
+
+type layerInfo struct {
+	Index          int    `json:"index"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	Activation     string `json:"activation"`
+	Connections    int    `json:"connections"` // incoming weights across the layer
+	FullyConnected bool   `json:"fully_connected"`
+	// Trainable is null: Paragon's JSON format does not persist per-layer
+	// trainable flags, so there is nothing truthful to report.
+	Trainable *bool `json:"trainable"`
+}
+
+func describeLayers(nn *paragon.Network[float32]) []layerInfo {
+	out := make([]layerInfo, len(nn.Layers))
+	for i := range nn.Layers {
+		// By pointer: copying the Layer would read the CachedOutputs a live
+		// forward writes.
+		L := &nn.Layers[i]
+		li := layerInfo{Index: i, Width: L.Width, Height: L.Height, Activation: "linear"}
+		if L.Height > 0 && L.Width > 0 && L.Neurons[0][0] != nil {
+			li.Activation = L.Neurons[0][0].Activation
+		}
+		for _, row := range L.Neurons {
+			for _, n := range row {
+				li.Connections += len(n.Inputs)
+			}
+		}
+		li.FullyConnected = isFullyConnected(nn, i)
+		out[i] = li
+	}
+	return out
+}
+
+// isFullyConnected reports whether every neuron in layer l takes input from
+// every neuron in layer l-1. The input layer counts as fully connected.
+func isFullyConnected(nn *paragon.Network[float32], l int) bool {
+	if l == 0 {
+		return true
+	}
+	prev := nn.Layers[l-1].Width * nn.Layers[l-1].Height
+	for _, row := range nn.Layers[l].Neurons {
+		for _, n := range row {
+			if len(n.Inputs) != prev {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *Server) handleModel(c *fiber.Ctx) error {
+	m := s.primaryModel()
+	return c.JSON(fiber.Map{
+		"model":   m.ModelName,
+		"type":    m.NN.TypeName,
+		"input":   []int{s.InputW, s.InputH},
+		"classes": s.ClassCount,
+		"layers":  describeLayers(m.NN),
+	})
+}
+
+// gpuActivations are the activations Paragon's WebGPU layer shader
+// implements. Anything else compiles to the shader's identity fallback.
+var gpuActivations = map[string]bool{
+	"linear": true, "relu": true, "leaky_relu": true, "elu": true, "tanh": true, "sigmoid": true,
+}
+
+type layerCoverage struct {
+	Index      int    `json:"index"`
+	Activation string `json:"activation"`
+	Device     string `json:"device"` // input | gpu | hybrid | cpu | unsupported
+	Note       string `json:"note,omitempty"`
+}
+
+type gpuCoverage struct {
+	Layers   []layerCoverage `json:"layers"`
+	OnGPU    int             `json:"on_gpu"`   // compute layers whose weights run on the GPU
+	Compute  int             `json:"compute"`  // layers after the input layer
+	Fraction float64         `json:"fraction"` // OnGPU / Compute
+}
+
+// coverage reports where each layer runs. Paragon builds the GPU pipeline
+// all-or-nothing and doesn't say which ops it would rather leave on the
+// CPU, so this is derived from the init outcome and the activations its
+// shader knows about.
+func (m *Model) coverage() gpuCoverage {
+	var cov gpuCoverage
+	last := len(m.NN.Layers) - 1
+	for _, li := range describeLayers(m.NN) {
+		lc := layerCoverage{Index: li.Index, Activation: li.Activation}
+		switch {
+		case li.Index == 0:
+			lc.Device = "input"
+		case !m.GPU:
+			lc.Device = "cpu"
+		case gpuActivations[li.Activation]:
+			lc.Device = "gpu"
+			cov.OnGPU++
+		case li.Activation == "softmax" && li.Index == last:
+			lc.Device, lc.Note = "hybrid", "weights on GPU, softmax applied on CPU after readback"
+			cov.OnGPU++
+		default:
+			lc.Device, lc.Note = "unsupported", "activation missing from the WebGPU shader; runs as linear on GPU"
+		}
+		cov.Layers = append(cov.Layers, lc)
+	}
+	cov.Compute = last
+	if cov.Compute > 0 {
+		cov.Fraction = float64(cov.OnGPU) / float64(cov.Compute)
+	}
+	return cov
+}
+
+// layerDevices is coverage for one forward: on GPU the layers run where
+// coverage says, and a forward that ran on the CPU ran every layer there.
+// Paragon doesn't time or place layers per forward, so this is as precise
+// as the outcome forward reports.
+func (m *Model) layerDevices(usedGPU bool) []layerCoverage {
+	layers := m.coverage().Layers
+	if usedGPU {
+		return layers
+	}
+	for i := range layers {
+		if layers[i].Device == "input" {
+			continue
+		}
+		layers[i].Device, layers[i].Note = "cpu", ""
+		if m.GPU {
+			layers[i].Note = "GPU model; this forward ran on the CPU"
+		}
+	}
+	return layers
+}
+
+// logCoverage warns when a GPU model runs little of its work on the GPU or
+// has layers the shader can't compute correctly.
+func (m *Model) logCoverage() {
+	if !m.GPU {
+		return
+	}
+	cov := m.coverage()
+	for _, lc := range cov.Layers {
+		if lc.Device == "unsupported" {
+			log.Printf("WARN: %s layer %d (%s): %s — GPU results will differ from CPU.", m.ModelName, lc.Index, lc.Activation, lc.Note)
+		}
+	}
+	if cov.Fraction < 0.5 {
+		log.Printf("WARN: %s: only %d of %d compute layers run on the GPU.", m.ModelName, cov.OnGPU, cov.Compute)
+	}
+}
+
+// weightSummary summarizes a set of weights or biases. Min, Max, Mean,
+// Std and L2 are over the finite values; NaN and Inf count the rest.
+type weightSummary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	Std   float64 `json:"std"`
+	L2    float64 `json:"l2_norm"`
+	NaN   int     `json:"nan"`
+	Inf   int     `json:"inf"`
+}
+
+// statsAcc accumulates a weightSummary in float64.
+type statsAcc struct {
+	weightSummary
+	sum, sumSq float64
+	seen       bool // a finite value set Min and Max
+}
+
+func (a *statsAcc) add(v float32) {
+	a.Count++
+	x := float64(v)
+	switch {
+	case math.IsNaN(x):
+		a.NaN++
+		return
+	case math.IsInf(x, 0):
+		a.Inf++
+		return
+	}
+	if !a.seen {
+		a.Min, a.Max, a.seen = x, x, true
+	}
+	a.Min, a.Max = min(a.Min, x), max(a.Max, x)
+	a.sum += x
+	a.sumSq += x * x
+}
+
+func (a *statsAcc) done() weightSummary {
+	st := a.weightSummary
+	if n := float64(st.Count - st.NaN - st.Inf); n > 0 {
+		st.Mean = a.sum / n
+		st.Std = math.Sqrt(max(a.sumSq/n-st.Mean*st.Mean, 0))
+		st.L2 = math.Sqrt(a.sumSq)
+	}
+	return st
+}
+
+type layerWeightStats struct {
+	Index   int           `json:"index"`
+	Weights weightSummary `json:"weights"` // incoming connection weights
+	Biases  weightSummary `json:"biases"`
+}
+
+type modelStats struct {
+	Model   string             `json:"model"`
+	Layers  []layerWeightStats `json:"layers"` // compute layers; the input layer has no weights
+	Weights weightSummary      `json:"weights"`
+	Finite  bool               `json:"finite"` // no NaN or Inf weight or bias anywhere
+}
+
+// weightStats walks every neuron of m after the input layer. An in-place
+// reload rewrites the biases and input slices of a live model under gpuMu,
+// so callers hold gpuMu unless m isn't serving yet, as at load.
+func (m *Model) weightStats() modelStats {
+	st := modelStats{Model: m.ModelName, Finite: true}
+	var all statsAcc
+	for i := 1; i < len(m.NN.Layers); i++ {
+		L := &m.NN.Layers[i]
+		var w, b statsAcc
+		for _, row := range L.Neurons {
+			for _, n := range row {
+				b.add(n.Bias)
+				for _, c := range n.Inputs {
+					w.add(c.Weight)
+					all.add(c.Weight)
+				}
+			}
+		}
+		ls := layerWeightStats{Index: i, Weights: w.done(), Biases: b.done()}
+		if ls.Weights.NaN+ls.Weights.Inf+ls.Biases.NaN+ls.Biases.Inf > 0 {
+			st.Finite = false
+		}
+		st.Layers = append(st.Layers, ls)
+	}
+	st.Weights = all.done()
+	return st
+}
+
+// handleModelStats serves /model/stats for the primary, or for the
+// registered model named by ?model=. It reads only resident models: a GET
+// for statistics shouldn't make the cache load an evicted one.
+func (s *Server) handleModelStats(c *fiber.Ctx) error {
+	m := s.primaryModel()
+	if name := c.Query("model"); name != "" && name != m.ModelName {
+		var registered bool
+		if m, registered = s.models.peek(name); !registered {
+			return fiber.NewError(fiber.StatusNotFound,
+				fmt.Sprintf("unknown model %q; registered: %s", name, strings.Join(s.modelOrder, ", ")))
+		}
+		if m == nil {
+			return fiber.NewError(fiber.StatusConflict,
+				fmt.Sprintf("model %q isn't loaded (-model-cache); its stats are served once a request loads it", name))
+		}
+	}
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	s.gpuMu.Lock()
+	st := m.weightStats()
+	s.gpuMu.Unlock()
+	return c.JSON(st)
+}
+
+// logWeightStats warns about layers with NaN or Inf weights or biases,
+// which load fine but make every forward through them meaningless.
+func (m *Model) logWeightStats() {
+	for _, ls := range m.weightStats().Layers {
+		if bad := ls.Weights.NaN + ls.Weights.Inf + ls.Biases.NaN + ls.Biases.Inf; bad > 0 {
+			log.Printf("WARN: %s layer %d has %d NaN/Inf weights or biases; see /model/stats.", m.ModelName, ls.Index, bad)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Content negotiation
+// ─────────────────────────────────────────────────────────────
+
+const mimeNDJSON = "application/x-ndjson"
+
+// negotiate picks the response type for the request's Accept header from
+// offers, first offer preferred on ties (and when Accept is absent). A
+// client that accepts none of them gets 406 listing what is available.
+func negotiate(c *fiber.Ctx, offers ...string) (string, error) {
+	if len(offers) == 0 {
+		offers = []string{fiber.MIMEApplicationJSON}
+	}
+	if t := c.Accepts(offers...); t != "" {
+		return t, nil
+	}
+	return "", fiber.NewError(fiber.StatusNotAcceptable,
+		fmt.Sprintf("cannot produce %s; available: %s", c.Get(fiber.HeaderAccept), strings.Join(offers, ", ")))
+}
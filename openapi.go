@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// OpenAPI description (GET /openapi.json)
+// ─────────────────────────────────────────────────────────────
+
+// mimeOpenAPI keeps the spec out of keyCase and envelope, which only touch
+// application/json: its property names are the snake_case wire names.
+const mimeOpenAPI = "application/vnd.oai.openapi+json;version=3.0"
+
+// apiOp documents one route. req and resp are zero values of the handler's
+// body types; the schemas are derived from their json tags, so they follow
+// the structs without a hand-kept copy. nil means no body (req) or a
+// free-form JSON object (resp).
+type apiOp struct {
+	summary string
+	req     any
+	resp    any
+	mime    string // response type when not application/json
+	reqMime string // request type when not application/json
+}
+
+// apiOps documents the inference and service routes by "METHOD path".
+// Routes missing here are still listed, with only their method and path.
+var apiOps = map[string]apiOp{
+	"GET /":                       {summary: "Home page", mime: fiber.MIMETextHTMLCharsetUTF8},
+	"GET /about":                  {summary: "About page", mime: fiber.MIMETextHTMLCharsetUTF8},
+	"GET /test":                   {summary: "Load test page", mime: fiber.MIMETextHTMLCharsetUTF8},
+	"GET /health":                 {summary: "Server status"},
+	"GET /config":                 {summary: "Model and preprocessing info"},
+	"GET /stats":                  {summary: "Forward counts, queues, latency percentiles, runtime health"},
+	"GET /stats/stream":           {summary: "Live /stats snapshots", mime: "text/event-stream"},
+	"GET /model":                  {summary: "Per-layer breakdown of the loaded network", resp: []layerInfo{}},
+	"GET /model/stats":            {summary: "Per-layer weight statistics of the loaded network", resp: modelStats{}},
+	"POST /infer":                 {summary: "Single inference", req: inferReq{}, resp: inferResp{}},
+	"POST /infer/embed":           {summary: "Prediction plus penultimate-layer embedding", req: inferReq{}, resp: embedResp{}},
+	"POST " + uploadPath:          {summary: "Inference on an uploaded image file", reqMime: "multipart/form-data", resp: inferResp{}},
+	"POST " + scorePath:           {summary: "Bulk NDJSON scoring, one /infer body per line", reqMime: mimeNDJSON, resp: batchLine{}, mime: mimeNDJSON},
+	"POST /infer-batch":           {summary: "Batch inference", req: batchReq{}, resp: batchResp{}},
+	"POST /blast":                 {summary: "N concurrent forwards (load test)", req: blastReq{}, resp: blastResp{}},
+	"POST /explain":               {summary: "Occlusion saliency map", req: explainReq{}, resp: explainResp{}},
+	"POST /interpolate":           {summary: "Class flips along the line from a to b", req: interpolateReq{}},
+	"POST /calibrate":             {summary: "Reliability diagram and ECE over a labeled set", req: calibrateReq{}, resp: calibrateResp{}},
+	"POST /profile/layers":        {summary: "Per-layer forward timing", req: profileReq{}, resp: profileResp{}},
+	"POST /verify":                {summary: "GPU vs CPU output divergence over a set", req: verifyReq{}, resp: verifyResp{}},
+	"POST /analyze":               {summary: "Dataset input statistics, no forwards", req: analyzeReq{}, resp: analyzeResp{}},
+	"POST /rpc":                   {summary: "JSON-RPC 2.0: infer, config, health", req: rpcRequest{}, resp: rpcResponse{}},
+	"POST /sessions/:name/replay": {summary: "Re-run a saved session through the current model"},
+	"GET /sessions/:name":         {summary: "Download a saved session file; supports Range"},
+	"GET /audit/verify":           {summary: "Check the -audit-log hash chain", resp: auditResult{}},
+	"GET /admin/config/export":    {summary: "Runtime settings as a file for /admin/config/import", resp: runtimeSettings{}},
+	"POST /admin/config/import":   {summary: "Apply exported runtime settings", req: runtimeSettings{}},
+	"POST /admin/reload":          {summary: "Reload the primary model as a warm standby"},
+	"GET /admin/inflight":         {summary: "Running inference requests", resp: []inflightItem{}},
+	"DELETE /admin/inflight/:id":  {summary: "Cancel a running request"},
+	"GET /admin/keys":             {summary: "Usage per -api-keys entry", resp: keysResp{}},
+}
+
+var openAPISpec struct {
+	once sync.Once
+	body []byte
+}
+
+// handleOpenAPI serves an OpenAPI 3 description of the routes the app has
+// registered, built on first request, when every route is in place.
+func (s *Server) handleOpenAPI(c *fiber.Ctx) error {
+	openAPISpec.once.Do(func() {
+		openAPISpec.body, _ = json.Marshal(s.openAPI(c.App().GetRoutes(true)))
+	})
+	c.Set(fiber.HeaderContentType, mimeOpenAPI)
+	return c.Send(openAPISpec.body)
+}
+
+func (s *Server) openAPI(routes []fiber.Route) fiber.Map {
+	g := schemaGen{defs: map[string]any{}}
+	paths := map[string]fiber.Map{}
+	for _, r := range routes {
+		if r.Method == fiber.MethodHead || r.Path == "/openapi.json" || strings.Contains(r.Path, "*") {
+			continue
+		}
+		path, params := openAPIPath(r.Path)
+		op := apiOps[r.Method+" "+r.Path]
+		item := fiber.Map{"responses": fiber.Map{
+			"200":     g.response(op),
+			"default": fiber.Map{"description": "Error message as plain text (JSON with -envelope)"},
+		}}
+		if op.summary != "" {
+			item["summary"] = op.summary
+		}
+		if len(params) > 0 {
+			item["parameters"] = params
+		}
+		if op.req != nil || op.reqMime != "" {
+			item["requestBody"] = g.requestBody(op)
+		}
+		if strings.HasPrefix(r.Path, "/admin") && s.adminToken != "" {
+			item["security"] = []fiber.Map{{"bearer": []string{}}}
+		}
+		// Every POST outside /admin is an inference or session route;
+		// session downloads are keyed like the saves, and model stats
+		// like the models they describe.
+		keyed := r.Path == "/sessions/:name" || r.Path == "/model/stats"
+		if (r.Method == fiber.MethodPost && !strings.HasPrefix(r.Path, "/admin") || keyed) && s.keys != nil {
+			item["security"] = []fiber.Map{{"apiKey": []string{}}}
+		}
+		if paths[path] == nil {
+			paths[path] = fiber.Map{}
+		}
+		paths[path][strings.ToLower(r.Method)] = item
+	}
+	return fiber.Map{
+		"openapi": "3.0.3",
+		"info": fiber.Map{
+			"title":       "Paragon inference server",
+			"version":     paragonVersion(),
+			"description": "Keys are snake_case; -json-case camel or X-JSON-Case: camel switches responses to camelCase.",
+		},
+		"paths": paths,
+		"components": fiber.Map{
+			"schemas": g.defs,
+			"securitySchemes": fiber.Map{
+				"bearer": fiber.Map{"type": "http", "scheme": "bearer", "description": "-admin-token, for /admin/*"},
+				"apiKey": fiber.Map{"type": "apiKey", "in": "header", "name": apiKeyHeader, "description": "-api-keys, for inference routes"},
+			},
+		},
+	}
+}
+
+// openAPIPath turns Fiber's /x/:id into OpenAPI's /x/{id} and lists the
+// path parameters.
+func openAPIPath(p string) (string, []fiber.Map) {
+	var params []fiber.Map
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if name, ok := strings.CutPrefix(part, ":"); ok {
+			parts[i] = "{" + name + "}"
+			params = append(params, fiber.Map{
+				"name": name, "in": "path", "required": true, "schema": fiber.Map{"type": "string"},
+			})
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// schemaGen derives JSON schemas from Go types, collecting named structs
+// under components/schemas.
+type schemaGen struct {
+	defs map[string]any
+}
+
+func (g *schemaGen) response(op apiOp) fiber.Map {
+	mime := op.mime
+	if mime == "" {
+		mime = fiber.MIMEApplicationJSON
+	}
+	schema := fiber.Map{"type": "object"}
+	switch {
+	case mime == "text/event-stream" || mime == fiber.MIMETextHTMLCharsetUTF8:
+		schema = fiber.Map{"type": "string"}
+	case op.resp != nil:
+		schema = g.schema(reflect.TypeOf(op.resp))
+	}
+	return fiber.Map{"description": "OK", "content": fiber.Map{mime: fiber.Map{"schema": schema}}}
+}
+
+func (g *schemaGen) requestBody(op apiOp) fiber.Map {
+	mime := op.reqMime
+	if mime == "" {
+		mime = fiber.MIMEApplicationJSON
+	}
+	schema := fiber.Map{"type": "object"}
+	if op.req != nil {
+		schema = g.schema(reflect.TypeOf(op.req))
+	}
+	if mime == "multipart/form-data" {
+		schema = fiber.Map{"type": "object", "properties": fiber.Map{
+			"image": fiber.Map{"type": "string", "format": "binary"},
+		}, "required": []string{"image"}}
+	}
+	return fiber.Map{"required": true, "content": fiber.Map{mime: fiber.Map{"schema": schema}}}
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	apiTimeType = reflect.TypeOf(apiTime{})
+)
+
+func (g *schemaGen) schema(t reflect.Type) fiber.Map {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return fiber.Map{"type": "string", "format": "date-time"}
+	case t == apiTimeType:
+		return timeSchema()
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = fiber.Map{} // placeholder against recursion
+			g.defs[t.Name()] = g.object(t)
+		}
+		return fiber.Map{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.object(t)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return fiber.Map{} // json.RawMessage: any JSON value
+		}
+		return fiber.Map{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return fiber.Map{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Bool:
+		return fiber.Map{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fiber.Map{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return fiber.Map{"type": "number"}
+	case reflect.String:
+		return fiber.Map{"type": "string"}
+	}
+	return fiber.Map{} // interface: any JSON value
+}
+
+// object lists a struct's exported fields by json name, inlining embedded
+// structs as encoding/json does.
+func (g *schemaGen) object(t reflect.Type) fiber.Map {
+	props := fiber.Map{}
+	var walk func(reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				walk(f.Type)
+				continue
+			}
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = g.schema(f.Type)
+		}
+	}
+	walk(t)
+	return fiber.Map{"type": "object", "properties": props}
+}
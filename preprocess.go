@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Input preprocessing & presets
+// ─────────────────────────────────────────────────────────────
+
+// preprocess describes how raw client pixels ([0,1], channels interleaved
+// along each row) are turned into model input. The zero value keeps the
+// server's original behaviour: clamp to [0,1] and nothing else.
+type preprocess struct {
+	Channels  int       `json:"channels"`  // values per pixel in model input (default 1)
+	Grayscale bool      `json:"grayscale"` // accept RGB input for 1-channel models (luma)
+	Resize    bool      `json:"resize"`    // bilinear-resize 2D images to the model size
+	Invert    bool      `json:"invert"`    // v → 1-v (dark-on-light drawings)
+	Mean      []float64 `json:"mean,omitempty"`
+	Std       []float64 `json:"std,omitempty"`
+}
+
+var presets = map[string]preprocess{
+	"mnist": {Channels: 1, Grayscale: true, Resize: true},
+	"cifar": {
+		Channels: 3, Resize: true,
+		Mean: []float64{0.4914, 0.4822, 0.4465},
+		Std:  []float64{0.2470, 0.2435, 0.2616},
+	},
+	"imagenet": {
+		Channels: 3, Resize: true,
+		Mean: []float64{0.485, 0.456, 0.406},
+		Std:  []float64{0.229, 0.224, 0.225},
+	},
+}
+
+func presetNames() []string { return []string{"mnist", "cifar", "imagenet"} }
+
+// validate checks p against the model input width.
+func (p *preprocess) validate(inputW int) error {
+	if p.Channels == 0 {
+		p.Channels = 1
+	}
+	if p.Channels < 1 || inputW%p.Channels != 0 {
+		return fmt.Errorf("channels=%d does not divide model input width %d", p.Channels, inputW)
+	}
+	if p.Grayscale && p.Channels != 1 {
+		return fmt.Errorf("grayscale conversion needs a 1-channel model")
+	}
+	for _, v := range [][]float64{p.Mean, p.Std} {
+		if len(v) != 0 && len(v) != p.Channels {
+			return fmt.Errorf("mean/std need %d values (one per channel)", p.Channels)
+		}
+	}
+	for _, sd := range p.Std {
+		if sd == 0 {
+			return fmt.Errorf("std must be non-zero")
+		}
+	}
+	return nil
+}
+
+// preprocessPath is the sidecar holding a model file's own preprocessing:
+// models/cifar.json → models/cifar.preprocess.json.
+func preprocessPath(modelPath string) string {
+	return strings.TrimSuffix(modelPath, filepath.Ext(modelPath)) + ".preprocess.json"
+}
+
+// loadPreprocessFile reads modelPath's sidecar, if there is one (never for
+// a URL). Its fields
+// are those of a preset; unknown ones are rejected so a typo doesn't
+// silently serve unprocessed input.
+func loadPreprocessFile(modelPath string, inputW int) (preprocess, bool, error) {
+	if isModelURL(modelPath) {
+		return preprocess{}, false, nil
+	}
+	path := preprocessPath(modelPath)
+	b, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return preprocess{}, false, nil
+	}
+	if err != nil {
+		return preprocess{}, false, err
+	}
+	var p preprocess
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return preprocess{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := p.validate(inputW); err != nil {
+		return preprocess{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, true, nil
+}
+
+// loadModelPreprocess reads the sidecar of the primary and of every -models
+// entry. A model with one uses it in place of -preset; the rest keep the
+// server-wide preprocessing.
+func loadModelPreprocess(primary *Model, reg *modelCache) (map[string]preprocess, error) {
+	paths := reg.registeredPaths()
+	paths[primary.ModelName] = primary.ModelPath
+	out := map[string]preprocess{}
+	for name, path := range paths {
+		p, ok, err := loadPreprocessFile(path, primary.InputW)
+		if err != nil {
+			return nil, fmt.Errorf("model %s preprocessing: %w", name, err)
+		}
+		if ok {
+			out[name] = p
+			log.Printf("Model %s: preprocessing from %s.", name, preprocessPath(path))
+		}
+	}
+	return out, nil
+}
+
+// preFor returns the preprocessing for the named model (empty: the active
+// one): its sidecar's, or the server-wide -preset.
+func (s *Server) preFor(name string) *preprocess {
+	if name == "" {
+		name = s.activeModel().ModelName
+	}
+	if p, ok := s.modelPre[name]; ok {
+		return &p
+	}
+	return &s.pre
+}
+
+// adaptFlat converts a flat RGB input to luma when grayscale is enabled
+// and the length says the client sent three channels.
+func (p *preprocess) adaptFlat(flat []float64, w, h int) []float64 {
+	if p.Grayscale && len(flat) == w*h*3 {
+		return rgbToGray(flat)
+	}
+	return flat
+}
+
+// adaptImage converts RGB rows to luma and resizes to h×w when enabled.
+func (p *preprocess) adaptImage(img [][]float64, w, h int) [][]float64 {
+	if p.Grayscale && len(img) > 0 && rowsHaveLen(img, len(img[0])) && len(img[0]) == 3*w {
+		gray := make([][]float64, len(img))
+		for r, row := range img {
+			gray[r] = rgbToGray(row)
+		}
+		img = gray
+	}
+	if p.Resize && len(img) > 0 && len(img[0]) > 0 && rowsHaveLen(img, len(img[0])) &&
+		(len(img) != h || len(img[0]) != w) && len(img[0])%p.Channels == 0 {
+		img = resizeBilinear(img, w/p.Channels, h, p.Channels)
+	}
+	return img
+}
+
+// pad centres img on an h×w canvas of fill when it is no larger than the
+// model input in either dimension. w counts values, so interleaved channels
+// are padded plane by plane to keep pixels whole. Anything else is returned
+// unchanged for resize or the size check to handle.
+func (p *preprocess) pad(img [][]float64, w, h int, fill float64) [][]float64 {
+	if len(img) == 0 || len(img[0]) == 0 || !rowsHaveLen(img, len(img[0])) ||
+		len(img) > h || len(img[0]) > w || len(img[0])%p.Channels != 0 ||
+		(len(img) == h && len(img[0]) == w) {
+		return img
+	}
+	ch := p.Channels
+	if ch == 1 {
+		return padMatrix(img, w, h, fill)
+	}
+	out := make([][]float64, h)
+	for r := range out {
+		out[r] = make([]float64, w)
+	}
+	for c := 0; c < ch; c++ {
+		plane := make([][]float64, len(img))
+		for r, row := range img {
+			plane[r] = make([]float64, len(row)/ch)
+			for x := range plane[r] {
+				plane[r][x] = row[x*ch+c]
+			}
+		}
+		for r, row := range padMatrix(plane, w/ch, h, fill) {
+			for x, v := range row {
+				out[r][x*ch+c] = v
+			}
+		}
+	}
+	return out
+}
+
+// padMatrix centres src on an h×w canvas filled with fill; odd margins put
+// the extra row or column after the image.
+func padMatrix(src [][]float64, w, h int, fill float64) [][]float64 {
+	y0, x0 := (h-len(src))/2, (w-len(src[0]))/2
+	out := make([][]float64, h)
+	for r := range out {
+		out[r] = make([]float64, w)
+		for x := range out[r] {
+			out[r][x] = fill
+		}
+		if r >= y0 && r < y0+len(src) {
+			copy(out[r][x0:], src[r-y0])
+		}
+	}
+	return out
+}
+
+// apply clamps to [0,1] then inverts and standardizes in place. It also
+// returns how many values the clamp changed.
+func (p *preprocess) apply(img [][]float64) ([][]float64, int) {
+	clamped := 0
+	for _, row := range img {
+		for c, v := range row {
+			if v < 0 || v > 1 {
+				v = math.Max(0, math.Min(1, v))
+				clamped++
+			}
+			if p.Invert {
+				v = 1 - v
+			}
+			ch := c % p.Channels
+			if len(p.Mean) > 0 {
+				v -= p.Mean[ch]
+			}
+			if len(p.Std) > 0 {
+				v /= p.Std[ch]
+			}
+			if inputExpr != nil {
+				v = inputExpr.eval(v)
+			}
+			row[c] = v
+		}
+	}
+	return img, clamped
+}
+
+func rgbToGray(v []float64) []float64 {
+	out := make([]float64, len(v)/3)
+	for i := range out {
+		out[i] = 0.299*v[3*i] + 0.587*v[3*i+1] + 0.114*v[3*i+2]
+	}
+	return out
+}
+
+// resizeBilinear scales an h×(w·ch) interleaved image to outH×(outW·ch).
+func resizeBilinear(img [][]float64, outW, outH, ch int) [][]float64 {
+	inH, inW := len(img), len(img[0])/ch
+	out := make([][]float64, outH)
+	for y := 0; y < outH; y++ {
+		out[y] = make([]float64, outW*ch)
+		fy := (float64(y)+0.5)*float64(inH)/float64(outH) - 0.5
+		y0 := int(math.Max(0, math.Floor(fy)))
+		y1 := min(y0+1, inH-1)
+		dy := math.Max(0, fy-float64(y0))
+		for x := 0; x < outW; x++ {
+			fx := (float64(x)+0.5)*float64(inW)/float64(outW) - 0.5
+			x0 := int(math.Max(0, math.Floor(fx)))
+			x1 := min(x0+1, inW-1)
+			dx := math.Max(0, fx-float64(x0))
+			for c := 0; c < ch; c++ {
+				a := img[y0][x0*ch+c]*(1-dx) + img[y0][x1*ch+c]*dx
+				b := img[y1][x0*ch+c]*(1-dx) + img[y1][x1*ch+c]*dx
+				out[y][x*ch+c] = a*(1-dy) + b*dy
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Intermediate layer probes (/infer probe_layer)
+// ─────────────────────────────────────────────────────────────
+
+type layerProbe struct {
+	Layer         int         `json:"layer"`
+	Width         int         `json:"width"`
+	Height        int         `json:"height"`
+	Activation    string      `json:"activation"`
+	PreActivation [][]float64 `json:"pre_activation"` // height×width: bias + Σ weight × input
+	Values        [][]float64 `json:"values"`         // height×width: after the activation
+}
+
+// checkProbe validates probe_layer against m's layers.
+func (m *Model) checkProbe(layer *int) error {
+	if layer != nil && (*layer < 0 || *layer >= len(m.NN.Layers)) {
+		return fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("probe_layer must be between 0 and %d for %s", len(m.NN.Layers)-1, m.ModelName))
+	}
+	return nil
+}
+
+// forwardProbe runs img on CPU, like forwardEmbed, and reads layer l back
+// as it stands after the forward. Paragon keeps only activated values, so
+// the pre-activation sums are recomputed from the layer's inputs the way
+// its forward adds them; for the input layer both are the input itself.
+// Like forwardCtx it doesn't start once ctx has ended.
+func (s *Server) forwardProbe(ctx context.Context, m *Model, img [][]float64, l int) ([]float64, *layerProbe, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctxErr(ctx)
+	}
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	cpuForward(m.NN, img)
+	atomic.AddInt64(&s.stats.cpuForwards, 1)
+	out := m.NN.ExtractOutput()
+	if len(out) == 0 {
+		return nil, nil, errNoOutput
+	}
+	L := m.NN.Layers[l]
+	p := &layerProbe{
+		Layer:         l,
+		Width:         L.Width,
+		Height:        L.Height,
+		Activation:    describeLayers(m.NN)[l].Activation,
+		PreActivation: make([][]float64, L.Height),
+		Values:        make([][]float64, L.Height),
+	}
+	for y, row := range L.Neurons {
+		p.PreActivation[y], p.Values[y] = make([]float64, len(row)), make([]float64, len(row))
+		for x, n := range row {
+			p.Values[y][x] = float64(n.Value)
+			if l == 0 {
+				p.PreActivation[y][x] = float64(n.Value)
+				continue
+			}
+			sum := n.Bias
+			for _, c := range n.Inputs {
+				sum += m.NN.Layers[c.SourceLayer].Neurons[c.SourceY][c.SourceX].Value * c.Weight
+			}
+			p.PreActivation[y][x] = float64(sum)
+		}
+	}
+	return out, p, nil
+}
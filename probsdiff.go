@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Sparse diff against baseline probs (/infer baseline_probs)
+// ─────────────────────────────────────────────────────────────
+
+type probsDiff struct {
+	Delta     float64     `json:"delta"`
+	Changed   []classDiff `json:"changed"` // by class index; [] when nothing moved
+	Unchanged int         `json:"unchanged"`
+}
+
+type classDiff struct {
+	Index    int     `json:"index"`
+	Label    string  `json:"label,omitempty"`
+	Score    float64 `json:"score"`    // as probs would report it
+	Baseline float64 `json:"baseline"` // the request's value
+	Change   float64 `json:"change"`   // score − baseline
+}
+
+// checkBaseline validates baseline_probs and diff_delta against m.
+func (m *Model) checkBaseline(base []float64, delta float64) error {
+	if base == nil {
+		return nil
+	}
+	if len(base) != m.ClassCount {
+		return fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("baseline_probs has %d values; %s has %d classes", len(base), m.ModelName, m.ClassCount))
+	}
+	for i, v := range base {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("baseline_probs[%d] is not a finite number", i))
+		}
+	}
+	if delta < 0 || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return fiber.NewError(fiber.StatusBadRequest, "diff_delta must be a finite number >= 0")
+	}
+	return nil
+}
+
+// diffProbs lists the classes whose score moved by more than delta from
+// base. Scores are the values probs would carry, so a previous response's
+// probs can be sent back as the baseline as they are.
+func (m *Model) diffProbs(out, base []float64, delta float64) *probsDiff {
+	d := &probsDiff{Delta: delta, Changed: []classDiff{}}
+	for i, v := range out {
+		if ch := v - base[i]; math.Abs(ch) > delta {
+			d.Changed = append(d.Changed, classDiff{Index: i, Label: m.label(i), Score: v, Baseline: base[i], Change: ch})
+		}
+	}
+	d.Unchanged = len(out) - len(d.Changed)
+	return d
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Per-layer forward timing (POST /profile/layers)
+// ─────────────────────────────────────────────────────────────
+
+const maxProfileRuns = 100
+
+type profileReq struct {
+	inferReq
+	Runs int `json:"runs"` // timed repetitions, medians reported (default 10)
+}
+
+type layerTiming struct {
+	Layer      int     `json:"layer"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Activation string  `json:"activation"`
+	Ms         float64 `json:"ms"`    // median time spent in this layer
+	Share      float64 `json:"share"` // fraction of sum_ms
+}
+
+type profileResp struct {
+	Layers     []layerTiming `json:"layers"`
+	SumMs      float64       `json:"sum_ms"`                 // Σ layer medians
+	TotalMs    float64       `json:"total_ms"`               // median full CPU forward
+	GPUTotalMs float64       `json:"gpu_total_ms,omitempty"` // median full GPU forward, when the model runs on GPU
+	Runs       int           `json:"runs"`
+	Model      string        `json:"model"`
+	LatencyMs  float64       `json:"latency_ms"`
+}
+
+// handleProfileLayers times a forward of one input layer by layer.
+// Paragon has no per-layer hooks and its GPU path dispatches every layer
+// in one submission, so the breakdown is measured on CPU from the layer
+// states of one forward (see profileCPU). On a GPU model the whole GPU
+// forward is timed as well, as gpu_total_ms.
+func (s *Server) handleProfileLayers(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	var req profileReq
+	if err := parseBody(c, &req, "'input' or 'image'"); err != nil {
+		return err
+	}
+	if req.Runs == 0 {
+		req.Runs = 10
+	}
+	if req.Runs < 1 || req.Runs > maxProfileRuns {
+		return fiber.NewError(fiber.StatusBadRequest, "runs must be 1..100")
+	}
+	img, err := s.normalizeInput(req.inferReq)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	m, err := s.lookupModel(req.Model)
+	if err != nil {
+		return err
+	}
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	trackModel(c, m.ModelName)
+	prio, err := parsePriority(req.Priority)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	slot, _, err := s.acquireN(c.UserContext(), prio, nil, req.Runs)
+	if err != nil {
+		return acquireError(err)
+	}
+	defer s.release(slot)
+	s.stats.observeSlot(slot)
+
+	start := time.Now()
+	layerMs, totalMs := s.profileCPU(m.NN, img, req.Runs)
+	resp := profileResp{Runs: req.Runs, Model: m.ModelName}
+	for i, ms := range layerMs {
+		L := m.NN.Layers[i+1]
+		resp.Layers = append(resp.Layers, layerTiming{
+			Layer: i + 1, Width: L.Width, Height: L.Height,
+			Activation: L.Neurons[0][0].Activation, Ms: ms,
+		})
+		resp.SumMs += ms
+	}
+	for i := range resp.Layers {
+		if resp.SumMs > 0 {
+			resp.Layers[i].Share = resp.Layers[i].Ms / resp.SumMs
+		}
+	}
+	resp.TotalMs = totalMs
+
+	if m.GPU {
+		var gpuMs []float64
+		for r := 0; r < req.Runs; r++ {
+			t0 := time.Now()
+			_, usedGPU, err := s.forwardCtx(c.UserContext(), m, img)
+			if err != nil {
+				return forwardError(err)
+			}
+			if usedGPU {
+				gpuMs = append(gpuMs, durMs(time.Since(t0)))
+			}
+		}
+		resp.GPUTotalMs, _, _ = percentilesOf(gpuMs)
+	}
+	resp.LatencyMs = durMs(time.Since(start))
+	return c.JSON(resp)
+}
+
+// profileCPU returns the median time of each layer after the input and of
+// a whole CPU forward over runs repetitions. A layer's time is the
+// difference between resuming the forward just before it and just after
+// it (ForwardFromLayer), so small layers near the output, timed over short
+// suffixes, aren't lost in the noise of the large early ones.
+func (s *Server) profileCPU(nn *paragon.Network[float32], img [][]float64, runs int) ([]float64, float64) {
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	out := nn.OutputLayer
+	cpuForward(nn, img)
+	states := make([][][]float64, out)
+	for l := range states {
+		states[l] = nn.GetLayerState(l)
+	}
+	per := make([][]float64, out)
+	total := make([]float64, 0, runs)
+	for r := 0; r < runs; r++ {
+		next := 0.0 // time of the suffix after layer l
+		for l := out; l >= 1; l-- {
+			t0 := time.Now()
+			nn.ForwardFromLayer(l-1, states[l-1])
+			suffix := durMs(time.Since(t0))
+			per[l-1] = append(per[l-1], max(suffix-next, 0))
+			next = suffix
+		}
+		t0 := time.Now()
+		cpuForward(nn, img)
+		total = append(total, durMs(time.Since(t0)))
+	}
+	layerMs := make([]float64, out)
+	for i, ms := range per {
+		layerMs[i], _, _ = percentilesOf(ms)
+	}
+	totalMs, _, _ := percentilesOf(total)
+	return layerMs, totalMs
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Quantized (int8/uint8) models
+// ─────────────────────────────────────────────────────────────
+
+// quantInfo maps an integer model's stored values to floats:
+// real = scale × (q − zero_point).
+type quantInfo struct {
+	Type      string  `json:"type"`
+	Scale     float64 `json:"scale"`
+	ZeroPoint float64 `json:"zero_point"`
+	Source    string  `json:"source"` // "model": its "quantization" field; "type": paragon's fixed-point default
+}
+
+// quantTypeMax is paragon's fixed-point range per integer type: it stores
+// x as round(x × max), so the default scale is 1/max with no zero point.
+var quantTypeMax = map[string]float64{"int8": math.MaxInt8, "uint8": math.MaxUint8}
+
+// readQuant returns the quantization of an integer model: the optional
+// top-level "quantization": {"scale", "zero_point"} the exporter wrote, or
+// paragon's default for the type. Float models get nil.
+func readQuant(data []byte) (*quantInfo, error) {
+	var head struct {
+		Type         string `json:"type"`
+		Quantization *struct {
+			Scale     float64 `json:"scale"`
+			ZeroPoint float64 `json:"zero_point"`
+		} `json:"quantization"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, nil // the parse proper reports malformed JSON
+	}
+	tmax, ok := quantTypeMax[head.Type]
+	if !ok {
+		return nil, nil
+	}
+	q := &quantInfo{Type: head.Type, Scale: 1 / tmax, Source: "type"}
+	if mq := head.Quantization; mq != nil {
+		if !(mq.Scale > 0) || math.IsInf(mq.Scale, 0) || math.IsNaN(mq.ZeroPoint) || math.IsInf(mq.ZeroPoint, 0) {
+			return nil, fmt.Errorf("quantization: scale must be > 0 and finite, zero_point finite (got %v, %v)", mq.Scale, mq.ZeroPoint)
+		}
+		q.Scale, q.ZeroPoint, q.Source = mq.Scale, mq.ZeroPoint, "model"
+	}
+	return q, nil
+}
+
+// dequantize turns a loaded integer network into a float32 one with the
+// same layout, so it runs on the float path every other model uses and its
+// outputs are probabilities/logits comparable to a float model's.
+func dequantize(loaded any, q *quantInfo) (*paragon.Network[float32], error) {
+	switch src := loaded.(type) {
+	case *paragon.Network[int8]:
+		return dequantizeNet(src, q)
+	case *paragon.Network[uint8]:
+		return dequantizeNet(src, q)
+	}
+	return nil, fmt.Errorf("model is %T; float32, int8 and uint8 models are supported", loaded)
+}
+
+func dequantizeNet[T int8 | uint8](src *paragon.Network[T], q *quantInfo) (*paragon.Network[float32], error) {
+	dst, err := paragon.ConvertNetwork[T, float32](src)
+	if err != nil {
+		return nil, err
+	}
+	real := func(v T) float32 { return float32(q.Scale * (float64(v) - q.ZeroPoint)) }
+	for l, L := range src.Layers {
+		for y, row := range L.Neurons {
+			for x, n := range row {
+				d := dst.Layers[l].Neurons[y][x]
+				d.Bias = real(n.Bias)
+				for i, c := range n.Inputs {
+					d.Inputs[i].Weight = real(c.Weight)
+				}
+			}
+		}
+	}
+	return dst, nil
+}
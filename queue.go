@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// GPU slot acquisition
+// ─────────────────────────────────────────────────────────────
+
+var (
+	errQueueTimeout = errors.New("timed out waiting for a GPU slot")
+	errDeadline     = errors.New("request deadline exceeded")
+)
+
+// waitError is a queue timeout or expired deadline together with how long
+// the request actually waited, which the error response reports.
+type waitError struct {
+	err    error
+	waited time.Duration
+}
+
+func (e *waitError) Error() string {
+	return fmt.Sprintf("%v (waited %s)", e.err, e.waited.Round(time.Millisecond))
+}
+
+func (e *waitError) Unwrap() error { return e.err }
+
+// timeoutHeaders tells the client how long the server is willing to wait,
+// so it can align its own timeouts: X-Deadline-Ms for -timeout and
+// X-Queue-Timeout-Ms for -queue-timeout, each only when set.
+func (s *Server) timeoutHeaders(c *fiber.Ctx) {
+	if s.reqTimeout > 0 {
+		c.Set("X-Deadline-Ms", strconv.FormatInt(s.reqTimeout.Milliseconds(), 10))
+	}
+	if s.queueTimeout > 0 {
+		c.Set("X-Queue-Timeout-Ms", strconv.FormatInt(s.queueTimeout.Milliseconds(), 10))
+	}
+}
+
+// withDeadline bounds ctx by -timeout from now. track applies it as a
+// request arrives, so the deadline covers all of it: queueing, every
+// forward and the work between them. Without -timeout ctx keeps only any
+// deadline it already has.
+func (s *Server) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.reqTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.reqTimeout)
+}
+
+// ctxErr is why ctx ended: errDeadline once its deadline has passed,
+// otherwise errCanceled (an operator cancel or a client that went away).
+func ctxErr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errDeadline
+	}
+	return errCanceled
+}
+
+// waitErr is ctxErr for a wait that began at start; a deadline says how
+// long the request waited.
+func waitErr(ctx context.Context, start time.Time) error {
+	if err := ctxErr(ctx); err != errDeadline {
+		return err
+	}
+	return &waitError{errDeadline, time.Since(start)}
+}
+
+// lane is an endpoint's own concurrency budget, taken before the shared
+// GPU slot, so a flood on one endpoint (e.g. /blast) can only occupy its
+// share of -maxgpu and leaves the rest to the others.
+type lane struct {
+	name     string
+	sem      chan struct{} // nil: no budget beyond the global cap
+	inflight atomic.Int64
+}
+
+func newLane(name string, n int) *lane {
+	l := &lane{name: name}
+	if n > 0 {
+		l.sem = make(chan struct{}, n)
+	}
+	return l
+}
+
+// acquire takes a GPU slot at priority p. The queue wait is bounded by
+// -queue-timeout and by ctx's deadline (-timeout) independently: whichever
+// fires first wins. Canceling ctx (/admin/inflight) ends the wait too.
+func (s *Server) acquire(ctx context.Context, p priority) (slot int, waited time.Duration, err error) {
+	return s.acquireN(ctx, p, nil, 1)
+}
+
+// acquireIn is acquire for an endpoint lane: it waits for one of l's tokens
+// first, then for the global slot, with both waits sharing one timeout.
+// A nil l only takes the global slot. Pair it with releaseIn.
+func (s *Server) acquireIn(ctx context.Context, p priority, l *lane) (slot int, waited time.Duration, err error) {
+	return s.acquireN(ctx, p, l, 1)
+}
+
+// acquireN is acquireIn for a caller that runs n forwards on the slot,
+// such as a batch; -global-rps charges it n tokens.
+func (s *Server) acquireN(ctx context.Context, p priority, l *lane, n int) (slot int, waited time.Duration, err error) {
+	if ctx.Err() != nil {
+		return -1, 0, waitErr(ctx, time.Now())
+	}
+	start := time.Now()
+	atomic.AddInt64(&s.queued, 1)
+	defer atomic.AddInt64(&s.queued, -1)
+
+	// -global-rps comes first: a token is spent per forward, and its wait
+	// counts against the same timeouts as the slot's.
+	limit, _ := ctx.Deadline()
+	if s.queueTimeout > 0 && (limit.IsZero() || start.Add(s.queueTimeout).Before(limit)) {
+		limit = start.Add(s.queueTimeout)
+	}
+	if err := s.throttle.take(ctx, limit, n); err != nil {
+		return -1, time.Since(start), err
+	}
+
+	// The timer is only armed once a wait is needed; ctx carries the
+	// deadline.
+	var queueC <-chan time.Time
+	arm := func() {
+		if queueC == nil && s.queueTimeout > 0 {
+			queueC = time.After(time.Until(start.Add(s.queueTimeout)))
+		}
+	}
+
+	if l != nil && l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		default:
+			arm()
+			select {
+			case l.sem <- struct{}{}:
+			case <-queueC:
+				err = &waitError{errQueueTimeout, time.Since(start)}
+			case <-ctx.Done():
+				err = waitErr(ctx, start)
+			}
+			if err != nil {
+				return -1, time.Since(start), err
+			}
+		}
+	}
+
+	// Fast path: a free slot needs no timers.
+	slot, w := s.slots.tryTake(p)
+	if w == nil {
+		l.enter()
+		return slot, time.Since(start), nil
+	}
+
+	arm()
+	select {
+	case slot = <-w.slot:
+		l.enter()
+		return slot, time.Since(start), nil
+	case <-queueC:
+		err = &waitError{errQueueTimeout, time.Since(start)}
+	case <-ctx.Done():
+		err = waitErr(ctx, start)
+	}
+	if !s.slots.cancel(p, w) {
+		// Granted while the timer fired; hand the slot on.
+		s.slots.release(<-w.slot)
+	}
+	l.leave()
+	return -1, time.Since(start), err
+}
+
+// releaseIn gives back a slot taken with acquireIn(…, l).
+func (s *Server) releaseIn(slot int, l *lane) {
+	s.slots.release(slot)
+	if l != nil {
+		l.inflight.Add(-1)
+	}
+	l.leave()
+}
+
+// size is how many requests l lets run at once under a global cap of max.
+func (l *lane) size(max int) int {
+	if l.sem == nil {
+		return max
+	}
+	return cap(l.sem)
+}
+
+// enter counts the request as running in l; leave hands l's token back.
+// Both are no-ops on a nil lane.
+func (l *lane) enter() {
+	if l != nil {
+		l.inflight.Add(1)
+	}
+}
+
+func (l *lane) leave() {
+	if l != nil && l.sem != nil {
+		<-l.sem
+	}
+}
+
+func (s *Server) release(slot int) {
+	s.slots.release(slot)
+}
+
+// acquireError maps an acquire failure to its HTTP status: queue timeouts
+// shed load with 503 (as do operator cancels), an expired request deadline is a 504,
+// and -global-rps refusals are 429.
+func acquireError(err error) error {
+	if errors.Is(err, errThrottled) {
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	}
+	if errors.Is(err, errQueueTimeout) || errors.Is(err, errCanceled) {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	return fiber.NewError(fiber.StatusGatewayTimeout, err.Error())
+}
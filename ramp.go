@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ─────────────────────────────────────────────────────────────
+// /blast ramp-up pacing
+// ─────────────────────────────────────────────────────────────
+
+const rampPhaseCount = 5
+
+// rampOpts spaces /blast submissions so the rate climbs linearly from zero
+// to TargetRPS over Seconds, then holds there until all N are submitted.
+type rampOpts struct {
+	Seconds   float64 `json:"ramp_seconds"`
+	TargetRPS float64 `json:"target_rps"`
+}
+
+// rampWindowMax bounds how long a ramped /blast may take to submit all n,
+// since the handler holds the connection until the last one finishes.
+const rampWindowMax = 300 * time.Second
+
+// validate checks the ramp for a blast of n.
+func (r *rampOpts) validate(n int) error {
+	if r.Seconds <= 0 || r.Seconds > 300 {
+		return fmt.Errorf("ramp_seconds must be within (0, 300]")
+	}
+	if r.TargetRPS <= 0 || r.TargetRPS > 10000 {
+		return fmt.Errorf("target_rps must be within (0, 10000]")
+	}
+	if w := r.offset(n - 1); w > rampWindowMax {
+		return fmt.Errorf("this ramp takes %s to submit %d requests, over the %s limit; raise target_rps or lower n",
+			w.Round(time.Second), n, rampWindowMax)
+	}
+	return nil
+}
+
+// offset is when submission i goes out. Under a linear ramp the cumulative
+// count is rps·t²/2T, so request i lands at √(2Ti/rps); past the ramp the
+// rate is flat.
+func (r *rampOpts) offset(i int) time.Duration {
+	inRamp := r.TargetRPS * r.Seconds / 2
+	var sec float64
+	if float64(i) <= inRamp {
+		sec = math.Sqrt(2 * r.Seconds * float64(i) / r.TargetRPS)
+	} else {
+		sec = r.Seconds + (float64(i)-inRamp)/r.TargetRPS
+	}
+	return time.Duration(sec * float64(time.Second))
+}
+
+type rampPhase struct {
+	Phase     int     `json:"phase"`
+	FromS     float64 `json:"from_s"`
+	ToS       float64 `json:"to_s"`
+	Submitted int     `json:"submitted"`
+	RPS       float64 `json:"rps"` // submission rate within the phase
+	Errors    int     `json:"errors"`
+	P50       float64 `json:"p50_ms"`
+	P90       float64 `json:"p90_ms"`
+	P99       float64 `json:"p99_ms"`
+}
+
+// rampPhases buckets results by submission time into equal slices of the
+// submission window and reports per-slice latency, so degradation shows up
+// as load climbs.
+func rampPhases(sent []time.Duration, results []inferResp) []rampPhase {
+	span := sent[len(sent)-1]
+	width := span / rampPhaseCount
+	lat := make([][]float64, rampPhaseCount)
+	phases := make([]rampPhase, rampPhaseCount)
+	for i := range phases {
+		phases[i] = rampPhase{
+			Phase: i,
+			FromS: (width * time.Duration(i)).Seconds(),
+			ToS:   (width * time.Duration(i+1)).Seconds(),
+		}
+	}
+	for i, at := range sent {
+		p := rampPhaseCount - 1
+		if width > 0 {
+			p = min(int(at/width), rampPhaseCount-1)
+		}
+		phases[p].Submitted++
+		if results[i].Error != "" {
+			phases[p].Errors++
+			continue
+		}
+		lat[p] = append(lat[p], results[i].LatencyMs)
+	}
+	for i := range phases {
+		ph := &phases[i]
+		if d := ph.ToS - ph.FromS; d > 0 {
+			ph.RPS = float64(ph.Submitted) / d
+		}
+		ph.P50, ph.P90, ph.P99 = percentilesOf(lat[i])
+	}
+	return phases
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gofiber/fiber/v2"
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Primary model reload (manual and -watch)
+// ─────────────────────────────────────────────────────────────
+
+const watchDebounce = 500 * time.Millisecond
+
+var errReloading = errors.New("a reload is already in progress")
+
+// modelSource is where the primary came from, so a reload reads it the
+// same way startup did.
+type modelSource struct {
+	path   string
+	bundle bool
+}
+
+// primaryModel is the live primary. Reloads swap it, so callers should
+// read it once per request and keep that *Model for every forward: a
+// retired model stays usable on CPU, only its GPU pipelines are freed.
+func (s *Server) primaryModel() *Model { return s.primary.Load() }
+
+// registered resolves a registry name to the live primary or a -models
+// entry, loading an entry -model-cache evicted. ok is false for names that
+// were never registered; err is a failed reload of an evicted one.
+func (s *Server) registered(name string) (m *Model, ok bool, err error) {
+	p := s.primaryModel()
+	if name == p.ModelName {
+		return p, true, nil
+	}
+	return s.models.get(name, p)
+}
+
+// reloadPrimary reads the primary's source again and swaps it in. The new
+// model must keep the primary's shape and inherits its labels; on any
+// error the old model keeps serving. Only one reload runs at a time.
+//
+// The new model is mounted as a standby while the old one keeps serving.
+// Its GPU init and warmup forward hold gpuMu, since Paragon's device and
+// queue are shared by every network, so forwards pause for them. The swap
+// is one pointer store. The old model is retired once the requests already
+// running on it have finished, or after -reload-drain, whichever comes
+// first.
+func (s *Server) reloadPrimary() (*Model, reloadTiming, error) {
+	var rt reloadTiming
+	if !s.reloading.CompareAndSwap(false, true) {
+		return nil, rt, errReloading
+	}
+	defer s.reloading.Store(false)
+
+	old := s.primaryModel()
+	var b *parsedBundle
+	var err error
+	if s.source.bundle {
+		b, err = parseBundle(s.source.path)
+	} else {
+		b, err = readModel(s.source.path)
+	}
+	if err != nil {
+		return nil, rt, err
+	}
+	// Reject a shape change before spending a GPU init on it.
+	if b.classes > 0 {
+		probe := &Model{
+			modelShape: modelShape{InputW: b.inW, InputH: b.inH, ClassCount: b.classes},
+			ModelName:  filepath.Base(s.source.path),
+		}
+		if err := sameShape(old, probe); err != nil {
+			return nil, rt, err
+		}
+	}
+	rt.SameLayout = sameLayout(old.NN, b.nn)
+
+	t0 := time.Now()
+	var m *Model
+	if rt.SameLayout {
+		m, rt.InPlace, err = s.syncWeights(old, b)
+		if err != nil {
+			log.Printf("WARN: loading %s's weights in place failed, building fresh pipelines: %v", old.ModelName, err)
+		}
+	}
+	if !rt.InPlace {
+		m, err = b.mount(s.source.path, &s.gpuMu)
+	}
+	rt.Standby = time.Since(t0)
+	if err != nil {
+		return nil, rt, err
+	}
+	if !rt.InPlace { // syncWeights checked an in-place model before keeping it
+		if err := sameShape(old, m); err != nil {
+			s.retire(m)
+			return nil, rt, err
+		}
+	}
+	m.Labels, m.LabelMeta = old.Labels, old.LabelMeta
+	swapped := time.Now()
+	s.primary.Store(m)
+	if rt.InPlace {
+		// old and m share the network, so there is nothing to drain or free.
+		log.Printf("Reloaded %s from %s in place (weights synced to its GPU pipelines in %s).",
+			m.ModelName, s.source.path, rt.Standby.Round(time.Millisecond))
+		return m, rt, nil
+	}
+
+	rt.Undrained = s.drainModel(old.ModelName, swapped, s.reloadDrain)
+	rt.Drain = time.Since(swapped)
+	t0 = time.Now()
+	s.retire(old)
+	rt.Retire = time.Since(t0)
+	log.Printf("Reloaded %s from %s (gpu=%v, same layout=%v, standby ready in %s, old model drained in %s, %d requests left to CPU, forwards held off %s).",
+		m.ModelName, s.source.path, m.GPU, rt.SameLayout, rt.Standby.Round(time.Millisecond),
+		rt.Drain.Round(time.Millisecond), rt.Undrained, (rt.Standby + rt.Retire).Round(time.Microsecond))
+	return m, rt, nil
+}
+
+// drainModel waits until no tracked request that started before since is
+// still on the model called name, for at most timeout, and returns how
+// many were left. Requests started after the swap don't count: a reload
+// keeps the name, so theirs is the new model's.
+func (s *Server) drainModel(name string, since time.Time, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		left := 0
+		s.reqs.m.Range(func(_, v any) bool {
+			t := v.(*tracked)
+			if t.Started.Before(since) && *t.model.Load() == name {
+				left++
+			}
+			return true
+		})
+		if left == 0 || !time.Now().Before(deadline) {
+			return left
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// reloadTiming breaks a reload down. Standby and Retire hold gpuMu, so
+// together they are the pause live forwards see.
+type reloadTiming struct {
+	SameLayout bool          // layers, activations and connectivity unchanged
+	InPlace    bool          // the new weights went into the live network's GPU buffers
+	Standby    time.Duration // GPU init and warmup of the new model, under gpuMu
+	Drain      time.Duration // waiting for requests on the old model to finish
+	Undrained  int           // requests still on the old model at -reload-drain; they finish on CPU
+	Retire     time.Duration // freeing the old model's pipelines
+}
+
+// syncWeights loads b's weights into old's live network and its GPU
+// buffers (SyncCPUWeightsToGPU) instead of building pipelines for a
+// standby. Paragon's GPU layers are dense weight and bias buffers sized by
+// the layer shapes, which sameLayout has checked, so they are reused as
+// they are. ok is false when old isn't on the GPU. The new model must
+// also pass sameShape. On any error old's own weights are put back and
+// synced again, so it keeps serving as it was; only if that second sync
+// fails too does it stay on CPU.
+func (s *Server) syncWeights(old *Model, b *parsedBundle) (m *Model, ok bool, err error) {
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	if !old.GPU || !old.NN.WebGPUNative {
+		return nil, false, nil
+	}
+	prev := weightsOf(old.NN)
+	rollback := func(cause error) (*Model, bool, error) {
+		setWeights(old.NN, prev)
+		if err := old.NN.SyncCPUWeightsToGPU(); err != nil {
+			old.NN.WebGPUNative = false
+			log.Printf("WARN: restoring %s's GPU weights failed; it serves on CPU until the next reload: %v", old.ModelName, err)
+		}
+		return nil, false, cause
+	}
+	setWeights(old.NN, weightsOf(b.nn)) // b.nn is dropped after this
+	if err := old.NN.SyncCPUWeightsToGPU(); err != nil {
+		return rollback(err)
+	}
+	if m, err = wrapModel(old.NN, b.inW, b.inH, b.classes, s.source.path); err != nil {
+		return rollback(err)
+	}
+	if err := sameShape(old, m); err != nil {
+		return rollback(err)
+	}
+	m.SHA256, m.Quant = b.sha, b.quant
+	return m, true, nil
+}
+
+// neuronWeights is what syncWeights replaces in a neuron.
+type neuronWeights struct {
+	bias   float32
+	inputs []paragon.Connection[float32]
+}
+
+func weightsOf(nn *paragon.Network[float32]) [][][]neuronWeights {
+	out := make([][][]neuronWeights, len(nn.Layers))
+	for l := range nn.Layers {
+		rows := nn.Layers[l].Neurons
+		out[l] = make([][]neuronWeights, len(rows))
+		for y, row := range rows {
+			out[l][y] = make([]neuronWeights, len(row))
+			for x, n := range row {
+				out[l][y][x] = neuronWeights{n.Bias, n.Inputs}
+			}
+		}
+	}
+	return out
+}
+
+// setWeights writes w into nn, which must have the layout w was taken from.
+func setWeights(nn *paragon.Network[float32], w [][][]neuronWeights) {
+	for l := range nn.Layers {
+		for y, row := range nn.Layers[l].Neurons {
+			for x, n := range row {
+				n.Bias, n.Inputs = w[l][y][x].bias, w[l][y][x].inputs
+			}
+		}
+	}
+}
+
+// sameLayout reports whether a and b have identical layer shapes,
+// activations and connectivity, so only their weights differ.
+func sameLayout(a, b *paragon.Network[float32]) bool {
+	la, lb := describeLayers(a), describeLayers(b)
+	if len(la) != len(lb) {
+		return false
+	}
+	for i := range la {
+		x, y := la[i], lb[i]
+		if x.Width != y.Width || x.Height != y.Height || x.Activation != y.Activation ||
+			x.Connections != y.Connections || x.FullyConnected != y.FullyConnected {
+			return false
+		}
+	}
+	return true
+}
+
+// retire frees m's GPU pipelines. Requests that picked m up before the swap
+// still finish: forward sees WebGPUNative off and runs them on CPU.
+func (s *Server) retire(m *Model) {
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	if m.NN.WebGPUNative {
+		m.NN.WebGPUNative = false
+		m.NN.CleanupOptimizedGPU()
+	}
+}
+
+func (s *Server) handleReload(c *fiber.Ctx) error {
+	m, rt, err := s.reloadPrimary()
+	if errors.Is(err, errReloading) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "reload failed, previous model still serving: "+err.Error())
+	}
+	return c.JSON(fiber.Map{
+		"reloaded": true,
+		"model":    m.ModelName,
+		"path":     s.source.path,
+		"gpu":      m.GPU,
+		// How long live forwards were held off the GPU.
+		"downtime_ms": durMs(rt.Standby + rt.Retire),
+		"standby_ms":  durMs(rt.Standby),
+		"drain_ms":    durMs(rt.Drain),
+		"undrained":   rt.Undrained,
+		"same_layout": rt.SameLayout,
+		"in_place":    rt.InPlace,
+	})
+}
+
+// watchModel reloads the primary whenever its file is written or replaced.
+// It watches the directory rather than the file so editors and deploy
+// tools that rename a new file into place are seen too. Bursts of events
+// collapse into one reload after watchDebounce of quiet.
+func (s *Server) watchModel() error {
+	if isModelURL(s.source.path) {
+		return fmt.Errorf("%s is a URL; use /admin/reload to fetch it again", s.source.path)
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	path := filepath.Clean(s.source.path)
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		w.Close()
+		return err
+	}
+	log.Printf("Watching %s for changes.", path)
+
+	go func() {
+		defer w.Close()
+		timer := time.NewTimer(watchDebounce)
+		timer.Stop()
+		for {
+			select {
+			case <-s.quit:
+				timer.Stop()
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != path || !ev.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				timer.Reset(watchDebounce)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("WARN: model watcher: %v", err)
+			case <-timer.C:
+				if _, err := os.Stat(path); err != nil {
+					continue // mid-replace; the Create will re-arm the timer
+				}
+				switch _, _, err := s.reloadPrimary(); {
+				case errors.Is(err, errReloading):
+					timer.Reset(watchDebounce) // try again once the other reload is done
+				case err != nil:
+					log.Printf("WARN: reload of %s failed, keeping the previous model: %v", path, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
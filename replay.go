@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Session replay
+// ─────────────────────────────────────────────────────────────
+
+// sessionDir holds /save-session files; a var so tests can use a temp dir.
+var sessionDir = "./data/sessions"
+
+const sessionModelMax = 64 // bytes of the model name in a session file name
+
+// saveSeq numbers the suffixes writeNew tries once a name is taken.
+var saveSeq atomic.Uint64
+
+// writeNew writes data to stem+ext, created with O_EXCL so a concurrent
+// save can never overwrite it. When the name is taken (two saves within the
+// clock's resolution) it retries as stem-N+ext and returns the path used.
+func writeNew(stem, ext string, data []byte) (string, error) {
+	path := stem + ext
+	for range 100 {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			path = fmt.Sprintf("%s-%d%s", stem, saveSeq.Add(1), ext)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		return path, nil
+	}
+	return "", fmt.Errorf("no free file name for %s%s", stem, ext)
+}
+
+// sessionFile maps a session name, as /save-session returned it, to its
+// file. Names that safeBase would change are refused rather than cleaned, so
+// a name can't reach outside sessionDir.
+func sessionFile(name string) (string, error) {
+	if name == "" || name != safeBase(name) || name == "." || name == ".." {
+		return "", fiber.NewError(fiber.StatusBadRequest, "invalid session name")
+	}
+	return filepath.Join(sessionDir, name+".json"), nil
+}
+
+// handleGetSession downloads a saved session as it was written. SendFile
+// serves Range requests, so an interrupted download can resume; fasthttp
+// doesn't check If-Range, so a validator that isn't the file's
+// Last-Modified drops the range and the whole file is sent, as RFC 9110
+// asks. Sessions are never rewritten, so that only happens for a
+// validator from elsewhere.
+func (s *Server) handleGetSession(c *fiber.Ctx) error {
+	name := c.Params("name")
+	path, err := sessionFile(name)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) || err == nil && !fi.Mode().IsRegular() {
+		return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("session %q not found", name))
+	} else if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if ir := c.Get(fiber.HeaderIfRange); ir != "" && ir != fi.ModTime().UTC().Format(http.TimeFormat) {
+		c.Request().Header.Del(fiber.HeaderRange)
+	}
+	c.Attachment(name + ".json")
+	return c.SendFile(path)
+}
+
+// savedSession is the part of a /save-session body replay needs. Each
+// result carries its input inline (input/image) or as input_ref into
+// inputs, which is how the test page stores a run's shared input once.
+type savedSession struct {
+	Model   string      `json:"model"`
+	Inputs  [][]float64 `json:"inputs"`
+	Results []struct {
+		TopIndex *int        `json:"top_index"`
+		TopScore float64     `json:"top_score"`
+		Input    []float64   `json:"input"`
+		Image    [][]float64 `json:"image"`
+		InputRef *int        `json:"input_ref"`
+	} `json:"results"`
+}
+
+type replayItem struct {
+	Index            int     `json:"index"` // position in the session's results
+	OriginalTopIndex *int    `json:"original_top_index"`
+	OriginalTopScore float64 `json:"original_top_score"`
+	TopIndex         int     `json:"top_index"`
+	TopLabel         string  `json:"top_label,omitempty"`
+	TopScore         float64 `json:"top_score"`
+	Changed          bool    `json:"changed"` // top class differs from the recording
+}
+
+// handleReplay re-runs a saved session's inputs through the current model,
+// in -max-batch chunks on the batch path, next to the recorded predictions.
+func (s *Server) handleReplay(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	name := c.Params("name")
+	path, err := sessionFile(name)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("session %q not found", name))
+	} else if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	var sess savedSession
+	if err := json.Unmarshal(b, &sess); err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "session: "+err.Error())
+	}
+
+	m := s.activeModel() // preprocessed for and forwarded on the same model
+	var (
+		imgs    [][][]float64
+		items   []replayItem
+		skipped []int
+	)
+	for i, r := range sess.Results {
+		req := inferReq{Input: r.Input, Image: r.Image, Model: m.ModelName}
+		if r.InputRef != nil && *r.InputRef >= 0 && *r.InputRef < len(sess.Inputs) {
+			req.Input = sess.Inputs[*r.InputRef]
+		}
+		if len(req.Input) == 0 && len(req.Image) == 0 {
+			skipped = append(skipped, i) // recorded without its input
+			continue
+		}
+		img, err := s.normalizeInput(req)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("session result %d: %v", i, err))
+		}
+		imgs = append(imgs, img)
+		items = append(items, replayItem{Index: i, OriginalTopIndex: r.TopIndex, OriginalTopScore: r.TopScore})
+	}
+
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	start := time.Now()
+	changed := 0
+	for lo := 0; lo < len(imgs); lo += s.maxBatch {
+		hi := min(lo+s.maxBatch, len(imgs))
+		slot, _, err := s.acquireN(c.UserContext(), prioLow, nil, hi-lo)
+		if err != nil {
+			return acquireError(err)
+		}
+		s.stats.observeSlot(slot)
+		resp, err := s.runBatch(c.UserContext(), m, imgs[lo:hi], slot, batchReq{})
+		s.release(slot)
+		if err != nil {
+			return err
+		}
+		for j, idx := range resp.TopIndices {
+			it := &items[lo+j]
+			it.TopIndex, it.TopLabel, it.TopScore = idx, m.label(idx), resp.TopScores[j]
+			if it.OriginalTopIndex != nil && *it.OriginalTopIndex != idx {
+				it.Changed = true
+				changed++
+			}
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"session":          name,
+		"recorded_model":   sess.Model,
+		"model":            m.ModelName,
+		"n":                len(items),
+		"changed":          changed,
+		"skipped_no_input": skipped,
+		"results":          items,
+		"latency_ms":       durMs(time.Since(start)),
+	})
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+)
+
+// ─────────────────────────────────────────────────────────────
+// JSON-RPC 2.0 (POST /rpc)
+// ─────────────────────────────────────────────────────────────
+
+// JSON-RPC 2.0 error codes (spec §5.1); -32000 is the first
+// implementation-defined server error.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"` // absent for notifications
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"` // HTTP status and any JSON error body
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcMethod is one callable method: the HTTP handler it runs and the
+// method/path it would be served on.
+type rpcMethod struct {
+	httpMethod string
+	path       string
+	handler    fiber.Handler
+}
+
+func (s *Server) rpcMethods() map[string]rpcMethod {
+	return map[string]rpcMethod{
+		"infer":  {fiber.MethodPost, "/infer", s.handleInfer},
+		"config": {fiber.MethodGet, "/config", s.handleConfig},
+		"health": {fiber.MethodGet, "/health", s.handleHealth},
+	}
+}
+
+// handleRPC serves JSON-RPC 2.0 single and batch calls. Each call runs the
+// same handler as its HTTP endpoint, with params as the request body
+// ("infer" takes the /infer body as its params object), so results are
+// the endpoint's JSON responses and share its validation. Notifications
+// (no id) run but get no response.
+func (s *Server) handleRPC(c *fiber.Ctx) error {
+	body := bytes.TrimSpace(c.Body())
+	if len(body) > 0 && body[0] == '[' {
+		var calls []json.RawMessage
+		if err := json.Unmarshal(body, &calls); err != nil {
+			return c.JSON(rpcFail(nil, rpcParseError, err.Error(), nil))
+		}
+		if len(calls) == 0 {
+			return c.JSON(rpcFail(nil, rpcInvalidRequest, "empty batch", nil))
+		}
+		// Each call may be a forward, so a batch is held to the same cap
+		// as an /infer-batch.
+		if len(calls) > s.maxBatch {
+			return c.JSON(rpcFail(nil, rpcInvalidRequest,
+				fmt.Sprintf("batch of %d calls exceeds -max-batch %d", len(calls), s.maxBatch), nil))
+		}
+		out := []rpcResponse{}
+		for _, raw := range calls {
+			if resp, ok := s.rpcCall(c, raw); ok {
+				out = append(out, resp)
+			}
+		}
+		if len(out) == 0 {
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+		return c.JSON(out)
+	}
+	if !json.Valid(body) {
+		return c.JSON(rpcFail(nil, rpcParseError, "request is not valid JSON", nil))
+	}
+	resp, ok := s.rpcCall(c, body)
+	if !ok {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	return c.JSON(resp)
+}
+
+// rpcCall runs one call. ok is false for a notification.
+func (s *Server) rpcCall(c *fiber.Ctx, raw []byte) (rpcResponse, bool) {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return rpcFail(nil, rpcInvalidRequest, err.Error(), nil), true
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFail(req.ID, rpcInvalidRequest, `need "jsonrpc":"2.0" and a method`, nil), true
+	}
+	m, found := s.rpcMethods()[req.Method]
+	var r rpcResponse
+	if !found {
+		r = rpcFail(req.ID, rpcMethodNotFound, "unknown method "+req.Method, nil)
+	} else {
+		r = rpcDispatch(c, m, req)
+	}
+	return r, len(req.ID) > 0
+}
+
+// rpcDispatch runs m's handler on a fresh request context carrying params
+// as a JSON body, and maps its outcome onto a JSON-RPC result or error.
+func rpcDispatch(c *fiber.Ctx, m rpcMethod, req rpcRequest) rpcResponse {
+	var fctx fasthttp.RequestCtx
+	fctx.Request.Header.SetMethod(m.httpMethod)
+	fctx.Request.SetRequestURI(m.path)
+	fctx.Request.Header.SetContentType(fiber.MIMEApplicationJSON)
+	fctx.Request.Header.Set(fiber.HeaderAccept, fiber.MIMEApplicationJSON)
+	if lang := c.Get(fiber.HeaderAcceptLanguage); lang != "" {
+		fctx.Request.Header.Set(fiber.HeaderAcceptLanguage, lang)
+	}
+	if params := bytes.TrimSpace(req.Params); len(params) > 0 && !bytes.Equal(params, []byte("null")) {
+		if params[0] != '{' {
+			return rpcFail(req.ID, rpcInvalidParams, "params must be an object", nil)
+		}
+		fctx.Request.SetBody(params)
+	} else if m.httpMethod == fiber.MethodPost {
+		fctx.Request.SetBody([]byte("{}"))
+	}
+
+	app := c.App()
+	inner := app.AcquireCtx(&fctx)
+	defer app.ReleaseCtx(inner)
+	inner.SetUserContext(c.UserContext()) // /admin/inflight cancels reach the handler
+	if t := c.Locals("tracked"); t != nil {
+		inner.Locals("tracked", t)
+	}
+	if k := c.Locals("apiKey"); k != nil {
+		inner.Locals("apiKey", k)
+	}
+
+	if err := m.handler(inner); err != nil {
+		code, status := rpcServerError, fiber.StatusInternalServerError
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			status = fe.Code
+			if status == fiber.StatusBadRequest {
+				code = rpcInvalidParams
+			}
+		}
+		return rpcFail(req.ID, code, err.Error(), fiber.Map{"status": status})
+	}
+	status, out := fctx.Response.StatusCode(), fctx.Response.Body()
+	if status >= fiber.StatusBadRequest {
+		// A handler that answered with its own JSON error body.
+		data := fiber.Map{"status": status}
+		msg := utils.StatusMessage(status)
+		var eb struct {
+			Error string `json:"error"`
+		}
+		if json.Valid(out) {
+			data["body"] = json.RawMessage(bytes.Clone(out))
+			if json.Unmarshal(out, &eb) == nil && eb.Error != "" {
+				msg = eb.Error
+			}
+		}
+		code := rpcServerError
+		if status == fiber.StatusBadRequest {
+			code = rpcInvalidParams
+		}
+		return rpcFail(req.ID, code, msg, data)
+	}
+	return rpcResponse{JSONRPC: "2.0", Result: bytes.Clone(out), ID: rpcID(req.ID)}
+}
+
+func rpcFail(id json.RawMessage, code int, msg string, data any) rpcResponse {
+	return rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: msg, Data: data}, ID: rpcID(id)}
+}
+
+// rpcID echoes the caller's id, or null when it couldn't be read.
+func rpcID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Runtime settings export/import (/admin/config/*)
+// ─────────────────────────────────────────────────────────────
+
+// runtimeSettings holds every setting that can change without a restart.
+// Fields are pointers so an imported file may carry a subset: what it
+// leaves out stays as it is. Everything else is a startup flag and is
+// reported by /config.
+type runtimeSettings struct {
+	InputMode *string `json:"input_mode,omitempty"`
+}
+
+// runtimeSettingNames lists the keys an import accepts, for its errors.
+var runtimeSettingNames = []string{"input_mode"}
+
+func (s *Server) runtimeSettings() runtimeSettings {
+	mode := s.inputMode()
+	return runtimeSettings{InputMode: &mode}
+}
+
+// validate checks every value before any is applied, so a bad file
+// changes nothing.
+func (rs runtimeSettings) validate() error {
+	if m := rs.InputMode; m != nil && *m != inputClamp && *m != inputStrict {
+		return fmt.Errorf("input_mode must be %q or %q", inputClamp, inputStrict)
+	}
+	return nil
+}
+
+// handleConfigExport serves GET /admin/config/export: the current runtime
+// settings as a file that POST /admin/config/import takes back as is.
+func (s *Server) handleConfigExport(c *fiber.Ctx) error {
+	c.Attachment("paragon-runtime-config.json")
+	return c.JSON(s.runtimeSettings())
+}
+
+// handleConfigImport serves POST /admin/config/import. Keys that aren't
+// runtime settings are rejected rather than ignored, so a file that tries
+// to change a startup flag says so instead of looking applied.
+func (s *Server) handleConfigImport(c *fiber.Ctx) error {
+	var rs runtimeSettings
+	dec := json.NewDecoder(bytes.NewReader(c.Body()))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rs); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("%v (runtime settings: %s; the rest are startup flags)", err, strings.Join(runtimeSettingNames, ", ")))
+	}
+	if err := rs.validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	prev := s.runtimeSettings()
+	if m := rs.InputMode; m != nil {
+		if old, _ := s.setInputMode(*m); old != *m {
+			log.Printf("Input mode %s → %s (config import).", old, *m)
+		}
+	}
+	return c.JSON(fiber.Map{"settings": s.runtimeSettings(), "previous": prev})
+}
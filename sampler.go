@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Prediction sampling to JSONL
+// ─────────────────────────────────────────────────────────────
+
+const sampleBuffer = 1024
+
+type sampleRecord struct {
+	When      time.Time `json:"when"`
+	Model     string    `json:"model"`
+	InputHash string    `json:"input_hash"`
+	TopIndex  int       `json:"top_index"`
+	Probs     []float64 `json:"probs"`
+}
+
+// sampler writes a random fraction of predictions to a JSONL file from a
+// background goroutine. When the buffer is full records are dropped rather
+// than slowing requests down.
+type sampler struct {
+	rate    float64
+	ch      chan sampleRecord
+	done    chan struct{}
+	mu      sync.RWMutex // guards closing ch against late senders
+	closed  bool
+	written int64
+	dropped int64
+}
+
+func newSampler(path string, rate float64) (*sampler, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	sp := &sampler{rate: rate, ch: make(chan sampleRecord, sampleBuffer), done: make(chan struct{})}
+	go sp.run(f)
+	return sp, nil
+}
+
+func (sp *sampler) run(f *os.File) {
+	defer close(sp.done)
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	flush := time.NewTicker(time.Second)
+	defer flush.Stop()
+	for {
+		select {
+		case rec, ok := <-sp.ch:
+			if !ok {
+				_ = w.Flush()
+				return
+			}
+			if err := enc.Encode(rec); err != nil {
+				log.Printf("WARN: sample write: %v", err)
+				continue
+			}
+			atomic.AddInt64(&sp.written, 1)
+		case <-flush.C:
+			_ = w.Flush()
+		}
+	}
+}
+
+// maybe records one prediction with probability rate. Safe on a nil sampler.
+func (sp *sampler) maybe(m *Model, img [][]float64, out []float64) {
+	if sp == nil || rand.Float64() >= sp.rate {
+		return
+	}
+	k := hashInput(m.ModelName, img)
+	rec := sampleRecord{
+		When:      time.Now(),
+		Model:     m.ModelName,
+		InputHash: hex.EncodeToString(k[:]),
+		TopIndex:  argmax64(out),
+		Probs:     append([]float64(nil), out...),
+	}
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	if sp.closed {
+		return
+	}
+	select {
+	case sp.ch <- rec:
+	default:
+		atomic.AddInt64(&sp.dropped, 1)
+	}
+}
+
+// close flushes buffered records; later maybe calls are ignored.
+func (sp *sampler) close() {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	sp.closed = true
+	close(sp.ch)
+	sp.mu.Unlock()
+	<-sp.done
+}
+
+func (sp *sampler) snapshot() fiber.Map {
+	return fiber.Map{
+		"rate":    sp.rate,
+		"written": atomic.LoadInt64(&sp.written),
+		"dropped": atomic.LoadInt64(&sp.dropped),
+	}
+}
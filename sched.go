@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ─────────────────────────────────────────────────────────────
+// GPU slot scheduler (priority queue)
+// ─────────────────────────────────────────────────────────────
+
+type priority int
+
+const (
+	prioHigh priority = iota
+	prioNormal
+	prioLow
+	prioLevels
+)
+
+var priorityNames = [prioLevels]string{"high", "normal", "low"}
+
+func (p priority) String() string { return priorityNames[p] }
+
+// parsePriority maps a request's "priority" field; empty is normal.
+func parsePriority(s string) (priority, error) {
+	if s == "" {
+		return prioNormal, nil
+	}
+	for p, name := range priorityNames {
+		if s == name {
+			return priority(p), nil
+		}
+	}
+	return prioNormal, fmt.Errorf("priority must be high, normal or low (got %q)", s)
+}
+
+// starveAfter bounds how long a queued request can be passed over: once the
+// head of a lower queue has waited this long it goes next regardless.
+const starveAfter = 2 * time.Second
+
+type waiter struct {
+	slot  chan int // buffered; receives the granted slot
+	since time.Time
+}
+
+// scheduler hands out GPU slot IDs 0..n-1. Free slots go straight to the
+// caller; otherwise callers queue by priority and release grants the slot
+// to the highest-priority waiter, FIFO within a level, unless a lower
+// level's head has been starving for starveAfter. With n == 0 there is no
+// limit: nobody queues, and a new ID is minted whenever none is free, so
+// IDs run up to the peak concurrency.
+type scheduler struct {
+	size int // 0: unlimited
+
+	mu     sync.Mutex
+	free   []int
+	minted int // IDs handed out so far when unlimited
+	queues [prioLevels][]*waiter
+}
+
+// checkGPUFlags validates -maxgpu and the lane budgets carved out of it:
+// -maxgpu may be 0 (no limit) or more, and each lane between 0 (no own
+// budget) and -maxgpu when that is set.
+func checkGPUFlags(maxGPU, maxInfer, maxBlast int) error {
+	if maxGPU < 0 {
+		return fmt.Errorf("-maxgpu must be 0 (no limit) or more, got %d", maxGPU)
+	}
+	for _, f := range []struct {
+		name string
+		n    int
+	}{{"-maxgpu-infer", maxInfer}, {"-maxgpu-blast", maxBlast}} {
+		if f.n < 0 || (maxGPU > 0 && f.n > maxGPU) {
+			return fmt.Errorf("%s must be between 0 and -maxgpu (%d), got %d", f.name, maxGPU, f.n)
+		}
+	}
+	return nil
+}
+
+func newScheduler(n int) *scheduler {
+	sc := &scheduler{size: n}
+	for i := n - 1; i >= 0; i-- {
+		sc.free = append(sc.free, i)
+	}
+	return sc
+}
+
+// tryTake returns a free slot when nobody is queued ahead, else enqueues a
+// waiter at p.
+func (sc *scheduler) tryTake(p priority) (int, *waiter) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if n := len(sc.free); n > 0 && sc.queuedLocked() == 0 {
+		slot := sc.free[n-1]
+		sc.free = sc.free[:n-1]
+		return slot, nil
+	}
+	if sc.size == 0 {
+		sc.minted++
+		return sc.minted - 1, nil
+	}
+	w := &waiter{slot: make(chan int, 1), since: time.Now()}
+	sc.queues[p] = append(sc.queues[p], w)
+	return -1, w
+}
+
+// cancel drops w from its queue. It reports false if w was already granted
+// a slot, which the caller must then take from w.slot.
+func (sc *scheduler) cancel(p priority, w *waiter) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	q := sc.queues[p]
+	for i, x := range q {
+		if x == w {
+			sc.queues[p] = append(q[:i], q[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (sc *scheduler) release(slot int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if w := sc.nextLocked(); w != nil {
+		w.slot <- slot
+		return
+	}
+	sc.free = append(sc.free, slot)
+}
+
+func (sc *scheduler) nextLocked() *waiter {
+	pick := -1
+	// Anti-starvation: the longest-waiting starving head wins.
+	for p := prioNormal; p < prioLevels; p++ {
+		if q := sc.queues[p]; len(q) > 0 && time.Since(q[0].since) >= starveAfter {
+			if pick < 0 || q[0].since.Before(sc.queues[pick][0].since) {
+				pick = int(p)
+			}
+		}
+	}
+	if pick < 0 {
+		for p := range sc.queues {
+			if len(sc.queues[p]) > 0 {
+				pick = p
+				break
+			}
+		}
+	}
+	if pick < 0 {
+		return nil
+	}
+	w := sc.queues[pick][0]
+	sc.queues[pick] = sc.queues[pick][1:]
+	return w
+}
+
+func (sc *scheduler) queuedLocked() int {
+	n := 0
+	for _, q := range sc.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// depths returns queue lengths by priority name, for /stats.
+func (sc *scheduler) depths() map[string]int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	out := make(map[string]int, prioLevels)
+	for p, q := range sc.queues {
+		out[priorityNames[p]] = len(q)
+	}
+	return out
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Bulk NDJSON scoring (POST /score)
+// ─────────────────────────────────────────────────────────────
+
+const (
+	scorePath       = "/score"
+	scoreLineMax    = 16 << 20 // bytes per input line
+	scoreMaxWorkers = 64
+)
+
+type scoreJob struct {
+	index int
+	line  []byte
+	res   chan batchLine // buffered; the writer reads it in input order
+}
+
+// handleScore scores an NDJSON stream of /infer bodies (or bare input
+// arrays), one per line, and streams one result line back per input, in
+// input order. The body is read as it arrives: a reader hands lines to a
+// pool of workers, and it stops reading while the results it is ahead by
+// (2×workers) haven't been written, so a slow client or a busy GPU holds
+// the upload back instead of buffering it. Query parameters (model,
+// top_k, priority, min_confidence, pad, pad_fill) set defaults for every
+// line; a line's own fields override them. A bad line yields an error line
+// and the job goes on.
+func (s *Server) handleScore(c *fiber.Ctx) error {
+	if _, err := negotiate(c, mimeNDJSON); err != nil {
+		return err
+	}
+	var opts inferReq
+	var optErr error
+	c.Context().QueryArgs().VisitAll(func(k, v []byte) {
+		if err := opts.setFormField(string(k), string(v)); err != nil && optErr == nil {
+			optErr = err
+		}
+	})
+	if optErr != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "query parameter "+optErr.Error())
+	}
+	workers := max(s.slots.size, 1)
+	if v := c.Query("workers"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > scoreMaxWorkers {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("workers must be 1..%d", scoreMaxWorkers))
+		}
+		workers = n
+	}
+
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(bytes.Clone(c.Body()))
+	}
+	conn := c.Context().Conn()
+	cfg := c.App().Config()
+	names := s.activeModel().requestLabels(c)
+	key := keyOf(c)
+	_, untrack := s.detach(c)
+	ctx := jobContext(c) // -timeout applies per line, in scoreOne
+	camel := wantsCamel(c)
+
+	c.Set(fiber.HeaderContentType, mimeNDJSON)
+	// An early stop leaves body unread, so the connection can't be reused.
+	c.Context().SetConnectionClose()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer untrack()
+		jobs := make(chan scoreJob, workers)
+		pending := make(chan chan batchLine, 2*workers)
+		stop := make(chan struct{})
+		readerDone := make(chan struct{})
+
+		go func() {
+			defer close(readerDone)
+			defer close(pending)
+			defer close(jobs)
+			sc := bufio.NewScanner(body)
+			sc.Buffer(make([]byte, 0, 64*1024), scoreLineMax)
+			for i := 0; ; {
+				if cfg.ReadTimeout > 0 {
+					// Per line: a long job may take any time in total.
+					conn.SetReadDeadline(time.Now().Add(cfg.ReadTimeout))
+				}
+				if !sc.Scan() {
+					break
+				}
+				line := bytes.TrimSpace(sc.Bytes())
+				if len(line) == 0 {
+					continue
+				}
+				j := scoreJob{index: i, line: bytes.Clone(line), res: make(chan batchLine, 1)}
+				i++
+				select {
+				case pending <- j.res:
+				case <-stop:
+					return
+				}
+				select {
+				case jobs <- j:
+				case <-stop:
+					return
+				}
+			}
+			if err := sc.Err(); err != nil {
+				res := make(chan batchLine, 1)
+				res <- batchLine{Index: -1, TopIndex: -1, Error: "reading input: " + err.Error()}
+				select {
+				case pending <- res:
+				case <-stop:
+				}
+			}
+		}()
+		for n := 0; n < workers; n++ {
+			go func() {
+				for j := range jobs {
+					j.res <- s.scoreOne(ctx, key, j.index, j.line, opts, names)
+				}
+			}()
+		}
+		defer func() {
+			close(stop)
+			conn.SetReadDeadline(time.Now()) // unblock a reader waiting on the client
+			<-readerDone
+		}()
+
+		for res := range pending {
+			if cfg.WriteTimeout > 0 {
+				conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+			}
+			if encodeLine(w, <-res, camel) != nil || w.Flush() != nil {
+				return // client went away
+			}
+		}
+	})
+	return nil
+}
+
+// scoreOne runs one NDJSON line as /infer would and returns its result
+// line; every failure is reported in the line rather than ending the job.
+func (s *Server) scoreOne(ctx context.Context, key *apiKey, index int, raw []byte, opts inferReq, names []string) batchLine {
+	ctx, cancel := s.withDeadline(ctx)
+	defer cancel()
+	fail := func(err error) batchLine {
+		return batchLine{Index: index, TopIndex: -1, Error: err.Error()}
+	}
+	req := opts
+	var err error
+	if raw[0] == '[' {
+		req.Input, req.Image = nil, nil
+		err = json.Unmarshal(raw, &req.Input)
+	} else {
+		err = json.Unmarshal(raw, &req)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	img, err := s.normalizeInput(req)
+	if err != nil {
+		return fail(err)
+	}
+	m, err := s.lookupModel(req.Model)
+	if err == nil {
+		err = key.allow(s.scopeName(m)) // per line: lines may name different models
+	}
+	if err != nil {
+		return fail(err)
+	}
+	br := batchReq{TopK: req.TopK, GroupBy: req.GroupBy, Order: req.Order, MinConf: req.MinConf, Softmax: req.Softmax}
+	warn, err := s.capTopK(&br.TopK, m.ClassCount)
+	if err == nil {
+		err = m.checkGroupBy(br.GroupBy)
+	}
+	if err == nil {
+		err = checkOrder(br.Order)
+	}
+	if err == nil {
+		err = checkMinConfidence(br.MinConf)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	prio, err := parsePriority(req.Priority)
+	if err != nil {
+		return fail(err)
+	}
+
+	slot, _, err := s.acquire(ctx, prio)
+	if err != nil {
+		return fail(err)
+	}
+	defer s.release(slot)
+	s.stats.observeSlot(slot)
+	t0 := time.Now()
+	out, usedGPU, err := s.forwardCtx(ctx, m, img)
+	if err != nil {
+		return fail(err)
+	}
+	lat := time.Since(t0)
+	s.stats.observe(lat)
+	s.sampler.maybe(m, img, out)
+	s.audit.record(m, img, out)
+	line := batchLine{Index: index, UsedGPU: usedGPU, LatencyMs: durMs(lat), Warning: warn}
+	m.fillLine(&line, out, br, names)
+	return line
+}
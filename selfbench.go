@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Startup GPU-vs-CPU benchmark (-selfbench)
+// ─────────────────────────────────────────────────────────────
+
+const selfBenchIters = 50
+
+type benchResult struct {
+	Iters   int     `json:"iters"`
+	GPUMs   float64 `json:"gpu_avg_ms"`
+	CPUMs   float64 `json:"cpu_avg_ms"`
+	Speedup float64 `json:"speedup"` // CPU time / GPU time; <1 means the GPU is slower
+	Error   string  `json:"error,omitempty"`
+}
+
+// selfBench times the same zero input on both paths. A software-rendered
+// adapter can initialize fine and still lose to the CPU, which is worth
+// knowing before taking traffic.
+func (s *Server) selfBench(m *Model) *benchResult {
+	res := &benchResult{Iters: selfBenchIters}
+	img := makeImage(m.InputW, m.InputH, 0)
+
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+
+	t0 := time.Now()
+	for range selfBenchIters {
+		if err := gpuForward(m.NN, img); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		_ = m.NN.ExtractOutput()
+	}
+	gpu := time.Since(t0)
+
+	t0 = time.Now()
+	for range selfBenchIters {
+		cpuForward(m.NN, img)
+		_ = m.NN.ExtractOutput()
+	}
+	cpu := time.Since(t0)
+
+	res.GPUMs = durMs(gpu) / selfBenchIters
+	res.CPUMs = durMs(cpu) / selfBenchIters
+	if gpu > 0 {
+		res.Speedup = float64(cpu) / float64(gpu)
+	}
+	return res
+}
+
+func (s *Server) runSelfBench(m *Model) {
+	if !m.GPU {
+		log.Printf("selfbench: skipped, %s is running on CPU.", m.ModelName)
+		return
+	}
+	s.bench = s.selfBench(m)
+	switch b := s.bench; {
+	case b.Error != "":
+		log.Printf("WARN: selfbench: GPU forward failed: %s", b.Error)
+	case b.Speedup < 1:
+		log.Printf("WARN: selfbench: GPU %.3fms vs CPU %.3fms (%.2fx) — the GPU is slower than CPU here; a software adapter?", b.GPUMs, b.CPUMs, b.Speedup)
+	default:
+		log.Printf("selfbench: GPU %.3fms vs CPU %.3fms (%.2fx speedup).", b.GPUMs, b.CPUMs, b.Speedup)
+	}
+}
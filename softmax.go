@@ -0,0 +1,48 @@
+package main
+
+import "strconv"
+
+// ─────────────────────────────────────────────────────────────
+// Softmax on returned scores (-softmax-default, "softmax")
+// ─────────────────────────────────────────────────────────────
+
+const (
+	softmaxAuto  = "auto"  // softmax unless the outputs are already probabilities
+	softmaxOn    = "true"  // the same, asked for explicitly
+	softmaxOff   = "false" // raw outputs
+	softmaxForce = "force" // softmax unless the output layer is softmax, overriding the detection
+)
+
+// softmaxDefault is set by -softmax-default before the server starts and
+// applies to requests that don't send "softmax".
+var softmaxDefault = softmaxAuto
+
+// softmaxes reports whether m's raw outputs are softmaxed before they are
+// returned, for a request's softmax field (nil: -softmax-default). auto and
+// true follow the load-time detection (OutputIsProb), so probabilities are
+// never normalized twice; only -softmax-default force trusts the output
+// layer's activation alone, for a model the detection got wrong.
+func (m *Model) softmaxes(want *bool) bool {
+	mode := softmaxDefault
+	if want != nil {
+		mode = strconv.FormatBool(*want)
+	}
+	switch mode {
+	case softmaxForce:
+		return m.OutputAct != "softmax"
+	case softmaxOff:
+		return false
+	}
+	return !m.OutputIsProb
+}
+
+// present returns out as the response shows it (probs, top_score, top_k),
+// the probabilities min_confidence and group_by see, and whether softmax
+// was applied to get the first.
+func (m *Model) present(out []float64, want *bool) (shown, norm []float64, softmaxed bool) {
+	if m.softmaxes(want) {
+		p := softmax64(out)
+		return p, p, true
+	}
+	return out, m.probs(out), false
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Runtime stats
+// ─────────────────────────────────────────────────────────────
+
+const latencyWindow = 2048 // recent forwards kept for percentiles
+
+type latSample struct {
+	at time.Time
+	ms float64
+}
+
+type stats struct {
+	forwards  int64   // total forwards served
+	gpuBusyNs int64   // wall time spent in successful GPU forwards
+	slotUse   []int64 // submissions per GPU slot (stream_id), under mu
+
+	gpuForwards int64 // single forwards by the device that ran them
+	cpuForwards int64
+	gpuOOM      int64 // GPU forwards that failed out of memory and ran on CPU
+
+	gpuFallbacks int64 // forwards of a GPU model that ran on CPU (breaker open, GPU error)
+	requests     int64 // tracked inference requests
+	errors       int64 // of those, ones whose handler failed
+
+	mu   sync.Mutex
+	ring [latencyWindow]latSample
+	next int
+}
+
+func (st *stats) observe(d time.Duration) {
+	atomic.AddInt64(&st.forwards, 1)
+	st.mu.Lock()
+	st.ring[st.next%latencyWindow] = latSample{at: time.Now(), ms: durMs(d)}
+	st.next++
+	st.mu.Unlock()
+}
+
+// position is how many forwards the latency ring has seen, for since.
+func (st *stats) position() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.next
+}
+
+// since returns the latencies recorded after position from, the current
+// position, and the fraction of those forwards still in the ring (1 unless
+// more than latencyWindow happened in between).
+func (st *stats) since(from int) ([]float64, int, float64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	start := max(from, st.next-latencyWindow)
+	ms := make([]float64, 0, st.next-start)
+	for i := start; i < st.next; i++ {
+		ms = append(ms, st.ring[i%latencyWindow].ms)
+	}
+	rate := 1.0
+	if st.next > from {
+		rate = float64(st.next-start) / float64(st.next-from)
+	}
+	return ms, st.next, rate
+}
+
+func (st *stats) observeGPU(d time.Duration) {
+	atomic.AddInt64(&st.gpuBusyNs, int64(d))
+}
+
+// observeSlot counts a submission on slot, growing the table for the
+// slots an unlimited scheduler (-maxgpu 0) mints on demand.
+func (st *stats) observeSlot(slot int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for slot >= len(st.slotUse) {
+		st.slotUse = append(st.slotUse, 0)
+	}
+	st.slotUse[slot]++
+}
+
+func (st *stats) slotCounts() []int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	out := make([]int64, len(st.slotUse))
+	copy(out, st.slotUse)
+	return out
+}
+
+// percentiles returns p50/p90/p99 over samples recorded after since
+// (zero time = the whole window) and how many samples were used.
+func (st *stats) percentiles(since time.Time) (p50, p90, p99 float64, n int) {
+	st.mu.Lock()
+	lat := make([]float64, 0, latencyWindow)
+	for _, smp := range st.ring {
+		if !smp.at.IsZero() && smp.at.After(since) {
+			lat = append(lat, smp.ms)
+		}
+	}
+	st.mu.Unlock()
+	p50, p90, p99 = percentilesOf(lat)
+	return p50, p90, p99, len(lat)
+}
+
+// percentilesOf sorts lat in place and returns its p50/p90/p99.
+func percentilesOf(lat []float64) (p50, p90, p99 float64) {
+	if len(lat) == 0 {
+		return 0, 0, 0
+	}
+	sort.Float64s(lat)
+	pick := func(p float64) float64 { return lat[int(p*float64(len(lat)-1))] }
+	return pick(0.50), pick(0.90), pick(0.99)
+}
+
+// laneInflight is the running request count per endpoint lane.
+func (s *Server) laneInflight() map[string]int64 {
+	out := make(map[string]int64, 2)
+	for _, l := range []*lane{s.lanes.infer, s.lanes.blast} {
+		out[l.name] = l.inflight.Load()
+	}
+	return out
+}
+
+func (s *Server) handleStats(c *fiber.Ctx) error {
+	p50, p90, p99, n := s.stats.percentiles(time.Time{})
+	out := fiber.Map{
+		"forwards":             atomic.LoadInt64(&s.stats.forwards),
+		"inflight":             atomic.LoadInt64(&s.inflight),
+		"queued":               atomic.LoadInt64(&s.queued),
+		"queued_by_priority":   s.slots.depths(),
+		"inflight_by_endpoint": s.laneInflight(),
+		"active_model":         s.activeModel().ModelName,
+		"streams":              s.stats.slotCounts(),
+		"dedup_shared":         atomic.LoadInt64(&s.flights.shared),
+		"forwards_by_device": fiber.Map{
+			"gpu":          atomic.LoadInt64(&s.stats.gpuForwards),
+			"cpu":          atomic.LoadInt64(&s.stats.cpuForwards),
+			"cpu_share":    cpuShare,
+			"gpu_oom":      atomic.LoadInt64(&s.stats.gpuOOM),
+			"gpu_fallback": atomic.LoadInt64(&s.stats.gpuFallbacks),
+		},
+		"latency_ms": fiber.Map{
+			"p50":     p50,
+			"p90":     p90,
+			"p99":     p99,
+			"samples": n,
+		},
+	}
+	out["breaker"] = s.breaker.snapshot()
+	out["model_cache"] = s.models.snapshot()
+	out["runtime"] = runtimeStats()
+	if s.sampler != nil {
+		out["sampling"] = s.sampler.snapshot()
+	}
+	if s.audit != nil {
+		out["audit"] = s.audit.snapshot()
+	}
+	if s.degrade != nil {
+		out["fallback"] = s.degrade.snapshot()
+	}
+	if s.throttle != nil {
+		out["global_rps"] = s.throttle.snapshot()
+	}
+	if s.bench != nil {
+		out["selfbench"] = s.bench
+	}
+	return c.JSON(out)
+}
+
+// runtimeStats samples Go runtime health; a goroutine count that keeps
+// climbing under /blast load points at a leak in request handling.
+func runtimeStats() fiber.Map {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return fiber.Map{
+		"goroutines":        runtime.NumGoroutine(),
+		"heap_alloc":        ms.HeapAlloc,
+		"heap_sys":          ms.HeapSys,
+		"num_gc":            ms.NumGC,
+		"last_gc_pause_ms":  durMs(time.Duration(ms.PauseNs[(ms.NumGC+255)%256])),
+		"gc_pause_total_ms": durMs(time.Duration(ms.PauseTotalNs)),
+	}
+}
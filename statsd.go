@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ─────────────────────────────────────────────────────────────
+// StatsD push (-statsd-addr)
+// ─────────────────────────────────────────────────────────────
+
+// statsdPacket keeps each UDP datagram under a typical path MTU, as StatsD
+// clients usually do, so no metric is lost to fragmentation.
+const statsdPacket = 1432
+
+// statsdPusher sends the server's counters and forward latencies to a
+// StatsD (or DogStatsD) agent every interval. Nothing is sent per request:
+// counters go out as the increase since the last flush, and timers are
+// read from the latency ring /stats already keeps.
+type statsdPusher struct {
+	conn   net.Conn
+	prefix string
+	every  time.Duration
+	stop   chan struct{}
+	done   chan struct{}
+
+	last     map[string]int64 // counter values at the last flush
+	lastNext int              // stats ring position at the last flush
+	errors   int64            // failed sends, logged once per flush
+}
+
+// startStatsd dials addr over UDP and starts flushing s's metrics.
+func startStatsd(s *Server, addr, prefix string, every time.Duration) (*statsdPusher, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	sp := &statsdPusher{
+		conn:   conn,
+		prefix: prefix,
+		every:  every,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		last:   map[string]int64{},
+	}
+	sp.lastNext = s.stats.position()
+	sp.counters(s) // start counting from now
+	go sp.run(s)
+	return sp, nil
+}
+
+func (sp *statsdPusher) run(s *Server) {
+	defer close(sp.done)
+	defer sp.conn.Close()
+	tick := time.NewTicker(sp.every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			sp.flush(s)
+		case <-sp.stop:
+			sp.flush(s)
+			return
+		}
+	}
+}
+
+// close sends what was counted since the last flush. Safe on nil.
+func (sp *statsdPusher) close() {
+	if sp == nil {
+		return
+	}
+	close(sp.stop)
+	<-sp.done
+}
+
+// counters returns each counter's increase since the previous call.
+func (sp *statsdPusher) counters(s *Server) map[string]int64 {
+	now := map[string]int64{
+		"requests":     atomic.LoadInt64(&s.stats.requests),
+		"errors":       atomic.LoadInt64(&s.stats.errors),
+		"forwards":     atomic.LoadInt64(&s.stats.forwards),
+		"forwards.gpu": atomic.LoadInt64(&s.stats.gpuForwards),
+		"forwards.cpu": atomic.LoadInt64(&s.stats.cpuForwards),
+		"gpu_fallback": atomic.LoadInt64(&s.stats.gpuFallbacks),
+		"gpu_oom":      atomic.LoadInt64(&s.stats.gpuOOM),
+	}
+	delta := make(map[string]int64, len(now))
+	for k, v := range now {
+		delta[k] = v - sp.last[k]
+	}
+	sp.last = now
+	return delta
+}
+
+func (sp *statsdPusher) flush(s *Server) {
+	var lines [][]byte
+	counts := sp.counters(s)
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		lines = append(lines, fmt.Appendf(nil, "%s.%s:%d|c", sp.prefix, name, counts[name]))
+	}
+	lat, next, rate := s.stats.since(sp.lastNext)
+	sp.lastNext = next
+	suffix := "|ms"
+	if rate < 1 {
+		// More forwards than the ring holds: the agent scales the
+		// timer count back up by the sample rate.
+		suffix += "|@" + strconv.FormatFloat(rate, 'f', 4, 64)
+	}
+	for _, ms := range lat {
+		lines = append(lines, fmt.Appendf(nil, "%s.latency:%s%s", sp.prefix, strconv.FormatFloat(ms, 'f', 3, 64), suffix))
+	}
+	lines = append(lines,
+		fmt.Appendf(nil, "%s.inflight:%d|g", sp.prefix, atomic.LoadInt64(&s.inflight)),
+		fmt.Appendf(nil, "%s.queued:%d|g", sp.prefix, atomic.LoadInt64(&s.queued)))
+
+	var pkt bytes.Buffer
+	send := func() {
+		if pkt.Len() == 0 {
+			return
+		}
+		if _, err := sp.conn.Write(pkt.Bytes()); err != nil {
+			sp.errors++
+		}
+		pkt.Reset()
+	}
+	for _, l := range lines {
+		if pkt.Len() > 0 && pkt.Len()+1+len(l) > statsdPacket {
+			send()
+		}
+		if pkt.Len() > 0 {
+			pkt.WriteByte('\n')
+		}
+		pkt.Write(l)
+	}
+	send()
+	if sp.errors > 0 {
+		log.Printf("WARN: statsd: %d sends failed", sp.errors)
+		sp.errors = 0
+	}
+}
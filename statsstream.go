@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Live stats (Server-Sent Events)
+// ─────────────────────────────────────────────────────────────
+
+type liveSnapshot struct {
+	At          apiTime `json:"at"`
+	InFlight    int64   `json:"inflight"`
+	Queued      int64   `json:"queued"`
+	Forwards    int64   `json:"forwards"`
+	Throughput  float64 `json:"throughput_rps"` // forwards/s since the previous snapshot
+	GPUBusy     float64 `json:"gpu_busy"`       // fraction of the interval spent in GPU forwards
+	P50         float64 `json:"p50_ms"`         // over the interval
+	P99         float64 `json:"p99_ms"`
+	ActiveModel string  `json:"active_model"`
+	Breaker     string  `json:"breaker"`
+}
+
+// handleStatsStream emits a stats snapshot every interval_ms (default
+// 1000, 250..10000). Paragon exposes no device utilization, so gpu_busy is
+// the share of wall time the server spent inside GPU forwards — with
+// forwards serialized on gpuMu that is how saturated the GPU path is. The
+// stream ends on client disconnect or shutdown and stops its ticker.
+func (s *Server) handleStatsStream(c *fiber.Ctx) error {
+	every := time.Duration(c.QueryInt("interval_ms", 1000)) * time.Millisecond
+	if every < 250*time.Millisecond || every > 10*time.Second {
+		return fiber.NewError(fiber.StatusBadRequest, "interval_ms must be 250..10000")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // keep reverse proxies from batching events
+
+	camel := wantsCamel(c)
+	conn, wt := c.Context().Conn(), c.App().Config().WriteTimeout
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		tick := time.NewTicker(every)
+		defer tick.Stop()
+		prevAt := time.Now()
+		prevFwd := atomic.LoadInt64(&s.stats.forwards)
+		prevBusy := atomic.LoadInt64(&s.stats.gpuBusyNs)
+		for {
+			select {
+			case <-s.quit:
+				return
+			case now := <-tick.C:
+				fwd := atomic.LoadInt64(&s.stats.forwards)
+				busy := atomic.LoadInt64(&s.stats.gpuBusyNs)
+				dt := now.Sub(prevAt)
+				p50, _, p99, _ := s.stats.percentiles(prevAt)
+				snap := liveSnapshot{
+					At:          apiTime(now),
+					InFlight:    atomic.LoadInt64(&s.inflight),
+					Queued:      atomic.LoadInt64(&s.queued),
+					Forwards:    fwd,
+					Throughput:  float64(fwd-prevFwd) / dt.Seconds(),
+					GPUBusy:     min(1, float64(busy-prevBusy)/float64(dt)),
+					P50:         p50,
+					P99:         p99,
+					ActiveModel: s.activeModel().ModelName,
+					Breaker:     s.breaker.stateName(),
+				}
+				prevAt, prevFwd, prevBusy = now, fwd, busy
+				b, _ := json.Marshal(snap)
+				if camel {
+					b = camelKeys(b)
+				}
+				conn.SetWriteDeadline(time.Now().Add(wt)) // per event; the stream has no end
+				if _, err := fmt.Fprintf(w, "event: stats\ndata: %s\n\n", b); err != nil {
+					return
+				}
+				if w.Flush() != nil {
+					return // client went away
+				}
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// NDJSON batch streaming
+// ─────────────────────────────────────────────────────────────
+
+// batchLine is one NDJSON line of a streamed /infer-batch response.
+type batchLine struct {
+	Index     int          `json:"index"`
+	TopIndex  int          `json:"top_index"`
+	TopLabel  string       `json:"top_label,omitempty"`
+	TopScore  float64      `json:"top_score"`
+	TopK      []classScore `json:"top_k,omitempty"`
+	TopGroups []groupScore `json:"top_groups,omitempty"`
+	Probs     any          `json:"probs,omitempty"` // as in inferResp
+	UsedGPU   bool         `json:"used_gpu"`
+	LatencyMs float64      `json:"latency_ms"`
+	Softmax   bool         `json:"softmax,omitempty"` // as in /infer
+	Error     string       `json:"error,omitempty"`
+	Warning   string       `json:"warning,omitempty"` // first line only, as in batchResp
+}
+
+// streamBatch writes one line per sample as soon as it is computed. The
+// forward loop feeds a small channel so compute keeps going while earlier
+// lines flush; a client disconnect stops the loop. It takes ownership of
+// slot and releases it when the loop ends. idxs maps imgs back to request
+// indices (nil = identity); invalid samples are written first as error lines.
+func (s *Server) streamBatch(c *fiber.Ctx, m *Model, imgs [][][]float64, idxs []int, invalid []sampleError, slot int, req batchReq) error {
+	ctx, untrack := s.detach(c)
+	names := m.requestLabels(c)
+	lines := make(chan batchLine, 16)
+	stop := make(chan struct{})
+
+	warn := req.warning
+	go func() {
+		defer close(lines)
+		defer s.release(slot)
+		defer untrack()
+		for _, e := range invalid {
+			select {
+			case lines <- batchLine{Index: e.Index, TopIndex: -1, Error: e.Error, Warning: warn}:
+			case <-stop:
+				return
+			}
+		}
+		for i, img := range imgs {
+			at := i
+			if idxs != nil {
+				at = idxs[i]
+			}
+			t0 := time.Now()
+			out, usedGPU, err := s.forwardCtx(ctx, m, img)
+			line := batchLine{Index: at, UsedGPU: usedGPU, LatencyMs: durMs(time.Since(t0))}
+			if err != nil {
+				line.TopIndex, line.Error = -1, err.Error()
+			} else {
+				s.stats.observe(time.Since(t0))
+				s.sampler.maybe(m, img, out)
+				s.audit.record(m, img, out)
+				one := req
+				one.TopK = req.topKAt(i)
+				m.fillLine(&line, out, one, names)
+			}
+			line.Warning, warn = warn, ""
+			select {
+			case lines <- line:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	camel := wantsCamel(c)
+	conn, wt := c.Context().Conn(), c.App().Config().WriteTimeout
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer close(stop)
+		for line := range lines {
+			// Per line, as /score does: the stream may outlast one timeout.
+			conn.SetWriteDeadline(time.Now().Add(wt))
+			if encodeLine(w, line, camel) != nil || w.Flush() != nil {
+				return // client went away
+			}
+		}
+	})
+	return nil
+}
+
+// fillLine sets line's prediction from out with req's top_k, group_by,
+// order, min_confidence and softmax, relabeled to names when set.
+func (m *Model) fillLine(line *batchLine, out []float64, req batchReq, names []string) {
+	k := req.TopK
+	out, norm, softmaxed := m.present(out, req.Softmax)
+	idx := argmax64(out)
+	line.TopIndex, line.TopLabel, line.TopScore, line.Softmax = idx, m.label(idx), out[idx], softmaxed
+	if unsure(norm, req.MinConf) {
+		line.TopIndex, line.TopLabel = -1, unknownLabel
+	}
+	if k > 0 {
+		line.TopK = m.topK(out, k)
+	} else {
+		line.Probs = m.ordered(out, req.Order)
+	}
+	if req.GroupBy != "" {
+		line.TopGroups = m.topGroups(norm, req.GroupBy, k)
+	}
+	relabel(names, line.TopIndex, &line.TopLabel)
+	relabelTopK(names, line.TopK)
+	relabelOrdered(names, line.Probs)
+}
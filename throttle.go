@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Global inference rate limit (-global-rps)
+// ─────────────────────────────────────────────────────────────
+
+var errThrottled = errors.New("global inference rate limit exceeded (-global-rps)")
+
+const rateBuckets = 10 // 100ms buckets behind the current rate in /stats
+
+// throttle is a token bucket over forwards, shared by every client and
+// endpoint: it refills at rate tokens per second up to burst, and each
+// acquire spends one per forward it will run. An admission that finds too
+// few tokens queues for them if they are due within wait, and is refused
+// otherwise. A nil throttle admits everything.
+type throttle struct {
+	rate  float64
+	burst float64
+	wait  time.Duration
+
+	mu     sync.Mutex
+	tokens float64 // negative while admissions are queued or a batch is paid off
+	last   time.Time
+
+	window [rateBuckets]int64 // forwards admitted per 100ms, ending at bucket
+	bucket int64
+
+	admitted atomic.Int64 // admissions, whatever their size
+	delayed  atomic.Int64 // of those, ones that queued for tokens
+	rejected atomic.Int64
+}
+
+func newThrottle(rate float64, burst int, wait time.Duration) *throttle {
+	b := float64(burst)
+	if burst == 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &throttle{rate: rate, burst: b, wait: wait, tokens: b, last: time.Now()}
+}
+
+// take spends n tokens, waiting for them if they are due within the
+// -global-rps wait and before limit (the request's deadline, if it has
+// one). It returns errThrottled without waiting when they are due later.
+// A batch larger than the burst only waits for a full bucket and leaves
+// the rest as debt, which later admissions wait out, so the rate still
+// holds over time.
+func (t *throttle) take(ctx context.Context, limit time.Time, n int) error {
+	if t == nil {
+		return nil
+	}
+	cost := float64(n)
+	t.mu.Lock()
+	now := time.Now()
+	t.refillLocked(now)
+	need := time.Duration(0)
+	if due := math.Min(cost, t.burst); t.tokens < due {
+		need = time.Duration((due - t.tokens) / t.rate * float64(time.Second))
+		if need > t.wait || !limit.IsZero() && now.Add(need).After(limit) {
+			t.mu.Unlock()
+			t.rejected.Add(1)
+			return errThrottled
+		}
+	}
+	t.tokens -= cost // reserved; given back if the wait is abandoned
+	t.mu.Unlock()
+
+	if need > 0 {
+		t.delayed.Add(1)
+		timer := time.NewTimer(need)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			t.mu.Lock()
+			t.tokens += cost
+			t.mu.Unlock()
+			return ctxErr(ctx)
+		}
+	}
+	t.mu.Lock()
+	t.countLocked(time.Now(), int64(n))
+	t.mu.Unlock()
+	t.admitted.Add(1)
+	return nil
+}
+
+func (t *throttle) refillLocked(now time.Time) {
+	t.tokens = math.Min(t.burst, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	t.last = now
+}
+
+// countLocked records n admitted forwards in the rate window, clearing
+// the buckets that have gone by since the last one.
+func (t *throttle) countLocked(now time.Time, n int64) {
+	t.rollLocked(now)
+	t.window[t.bucket%rateBuckets] += n
+}
+
+func (t *throttle) rollLocked(now time.Time) {
+	b := now.UnixMilli() / 100
+	if b-t.bucket >= rateBuckets {
+		t.window = [rateBuckets]int64{}
+		t.bucket = b
+	}
+	for t.bucket < b {
+		t.bucket++
+		t.window[t.bucket%rateBuckets] = 0
+	}
+}
+
+// retryAfter is the Retry-After for a refused request: whole seconds until
+// the queue the refusal found has drained, at least 1.
+func (t *throttle) retryAfter() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refillLocked(time.Now())
+	return strconv.Itoa(max(1, int(math.Ceil((1-t.tokens)/t.rate))))
+}
+
+// setRetryAfter adds Retry-After to a 429 from the throttle.
+func (s *Server) setRetryAfter(c *fiber.Ctx, err error) {
+	var fe *fiber.Error
+	if s.throttle != nil && errors.As(err, &fe) && fe.Code == fiber.StatusTooManyRequests {
+		c.Set(fiber.HeaderRetryAfter, s.throttle.retryAfter())
+	}
+}
+
+// snapshot is the /stats view. current_rps counts forwards admitted over
+// the last second.
+func (t *throttle) snapshot() fiber.Map {
+	t.mu.Lock()
+	t.rollLocked(time.Now())
+	var recent int64
+	for _, n := range t.window {
+		recent += n
+	}
+	t.mu.Unlock()
+	return fiber.Map{
+		"limit":       t.rate,
+		"burst":       t.burst,
+		"max_wait_ms": durMs(t.wait),
+		"current_rps": recent,
+		"admitted":    t.admitted.Load(),
+		"delayed":     t.delayed.Load(),
+		"rejected":    t.rejected.Load(),
+	}
+}
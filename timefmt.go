@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Response timestamp format (-time-format)
+// ─────────────────────────────────────────────────────────────
+
+const (
+	timeRFC3339 = "rfc3339"
+	timeUnix    = "unix"
+	timeUnixMs  = "unix_ms"
+)
+
+// timeFormat is how responses carry timestamps, set by -time-format before
+// the server starts.
+var timeFormat = timeRFC3339
+
+// apiTime is a timestamp in a response body. It marshals as an RFC 3339
+// string in UTC, or as integer seconds or milliseconds since the epoch.
+// Files the server writes (sessions, samples, the audit log) keep
+// time.Time, so their format doesn't depend on a flag.
+type apiTime time.Time
+
+func (t apiTime) MarshalJSON() ([]byte, error) {
+	tt := time.Time(t)
+	switch timeFormat {
+	case timeUnix:
+		return strconv.AppendInt(nil, tt.Unix(), 10), nil
+	case timeUnixMs:
+		return strconv.AppendInt(nil, tt.UnixMilli(), 10), nil
+	}
+	return tt.UTC().MarshalJSON()
+}
+
+// timeSchema is apiTime's OpenAPI schema under the current -time-format.
+func timeSchema() fiber.Map {
+	if timeFormat == timeRFC3339 {
+		return fiber.Map{"type": "string", "format": "date-time"}
+	}
+	return fiber.Map{"type": "integer", "format": "int64"}
+}
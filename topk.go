@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Top-k ranking
+// ─────────────────────────────────────────────────────────────
+
+type classScore struct {
+	Index int     `json:"index"`
+	Label string  `json:"label,omitempty"`
+	Score float64 `json:"score"`
+}
+
+// topK returns the k highest-scoring classes, best first. Ties keep index
+// order so results are stable across runs.
+func (m *Model) topK(probs []float64, k int) []classScore {
+	k = min(k, len(probs))
+	if k <= 0 {
+		return nil
+	}
+	idx := make([]int, len(probs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return probs[idx[a]] > probs[idx[b]] })
+	out := make([]classScore, k)
+	for i := range out {
+		out[i] = classScore{Index: idx[i], Label: m.label(idx[i]), Score: probs[idx[i]]}
+	}
+	return out
+}
+
+// capTopK validates a request's top_k and clamps it to -max-topk, so a huge
+// k can't bring back the whole class vector top_k was meant to avoid. When
+// k is unset on a model with more than -max-probs classes it becomes
+// -max-probs, so the full probs vector isn't shipped by default. The
+// returned warning is non-empty when k was changed.
+func (s *Server) capTopK(k *int, classes int) (string, error) {
+	if *k < 0 {
+		return "", fiber.NewError(fiber.StatusBadRequest, "top_k must be ≥ 0")
+	}
+	if s.maxTopK > 0 && *k > s.maxTopK {
+		warn := fmt.Sprintf("top_k %d clamped to -max-topk %d", *k, s.maxTopK)
+		*k = s.maxTopK
+		return warn, nil
+	}
+	if *k == 0 && s.maxProbs > 0 && classes > s.maxProbs {
+		*k = s.maxProbs
+		return fmt.Sprintf("probs truncated to the top %d of %d classes (-max-probs); send top_k for a different count", s.maxProbs, classes), nil
+	}
+	return "", nil
+}
+
+// checkKs validates a batch's per-sample ks against its total samples and
+// caps each entry as capTopK does, returning the first clamp warning.
+func (s *Server) checkKs(req batchReq, total, classes int) (string, error) {
+	if req.TopK != 0 {
+		return "", fiber.NewError(fiber.StatusBadRequest, "send top_k or ks, not both")
+	}
+	if len(req.Ks) != total {
+		return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("ks has %d entries for %d samples", len(req.Ks), total))
+	}
+	var warning string
+	for i := range req.Ks {
+		if req.Ks[i] < 1 {
+			return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("ks[%d] must be ≥ 1", i))
+		}
+		if warn, _ := s.capTopK(&req.Ks[i], classes); warn != "" && warning == "" {
+			warning = fmt.Sprintf("ks[%d]: %s", i, warn)
+		}
+	}
+	return warning, nil
+}
+
+// topKAt is sample i's top_k: its ks entry when ks was sent.
+func (req batchReq) topKAt(i int) int {
+	if req.Ks != nil {
+		return req.Ks[i]
+	}
+	return req.TopK
+}
+
+type groupScore struct {
+	Group   string  `json:"group"`
+	Score   float64 `json:"score"`   // summed probability of the group's classes
+	Classes int     `json:"classes"` // classes in the group
+}
+
+// hasGroup reports whether any class label carries metadata key.
+func (m *Model) hasGroup(key string) bool {
+	for _, meta := range m.LabelMeta {
+		if meta[key] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// topGroups sums probs by each class's label metadata value under key and
+// ranks the groups, best first; k ≤ 0 keeps them all. Classes without the
+// key are left out. probs must already be normalized (see m.probs).
+func (m *Model) topGroups(probs []float64, key string, k int) []groupScore {
+	var groups []groupScore
+	at := map[string]int{}
+	for i, p := range probs {
+		if i >= len(m.LabelMeta) {
+			break
+		}
+		g := m.LabelMeta[i][key]
+		if g == "" {
+			continue
+		}
+		j, ok := at[g]
+		if !ok {
+			j = len(groups)
+			at[g] = j
+			groups = append(groups, groupScore{Group: g})
+		}
+		groups[j].Score += p
+		groups[j].Classes++
+	}
+	sort.SliceStable(groups, func(a, b int) bool { return groups[a].Score > groups[b].Score })
+	if k > 0 && k < len(groups) {
+		groups = groups[:k]
+	}
+	return groups
+}
+
+// unknownLabel is top_label when the best class misses min_confidence.
+const unknownLabel = "unknown"
+
+func checkMinConfidence(v float64) error {
+	if v < 0 || v > 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "min_confidence must be within 0..1")
+	}
+	return nil
+}
+
+// unsure reports whether the best of the normalized probs falls short of
+// min; min = 0 never rejects.
+func unsure(probs []float64, min float64) bool {
+	return min > 0 && probs[argmax64(probs)] < min
+}
+
+// Orders for the probs field.
+const (
+	orderIndex = "index"      // plain array in class-index order (default)
+	orderScore = "score_desc" // {index, label, score} pairs, best first
+)
+
+func checkOrder(order string) error {
+	switch order {
+	case "", orderIndex, orderScore:
+		return nil
+	}
+	return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("order must be %s or %s", orderIndex, orderScore))
+}
+
+// ordered returns probs for a response's probs field: as is, or ranked as
+// classScore pairs for score_desc.
+func (m *Model) ordered(probs []float64, order string) any {
+	if order == orderScore {
+		return m.topK(probs, len(probs))
+	}
+	return probs
+}
+
+// relabelOrdered relabels probs returned by ordered when they are ranked.
+func relabelOrdered(names []string, probs any) {
+	if ks, ok := probs.([]classScore); ok {
+		relabelTopK(names, ks)
+	}
+}
+
+// checkGroupBy validates a request's group_by against the loaded labels.
+func (m *Model) checkGroupBy(key string) error {
+	if key != "" && !m.hasGroup(key) {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("group_by %q: no label metadata has that key", key))
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Streaming image upload (POST /infer/upload)
+// ─────────────────────────────────────────────────────────────
+
+const (
+	uploadPath      = "/infer/upload"
+	uploadFieldMax  = 256     // bytes per non-file form field
+	uploadHeaderMax = 1 << 20 // bytes DecodeConfig may read before the pixels
+)
+
+// The server runs with StreamRequestBody so uploads can be read as they
+// arrive, but then bodies over the limit reach handlers as streams instead
+// of being refused. bufferBody restores the buffered, limit-capped body
+// every route but the streaming ones (/infer/upload, /score) expects.
+func bufferBody(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if p := c.Path(); p == uploadPath || p == scorePath || !c.Request().IsBodyStream() {
+			return c.Next()
+		}
+		b, err := io.ReadAll(io.LimitReader(c.Context().RequestBodyStream(), int64(limit)+1))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if len(b) > limit {
+			return fiber.ErrRequestEntityTooLarge
+		}
+		c.Request().SetBody(b)
+		return c.Next()
+	}
+}
+
+// handleUpload runs /infer on an image file (PNG, JPEG or GIF) sent as
+// the "image" field of a multipart form. The body is read as a stream:
+// the image header is checked against -max-upload-pixels before any pixel
+// is decoded, so an oversized upload fails without being buffered. Option
+// fields (model, top_k, priority, min_confidence, pad, pad_fill) must come
+// before the file; nothing after it is read.
+func (s *Server) handleUpload(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	boundary := string(c.Request().Header.MultipartFormBoundary())
+	if boundary == "" {
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, "send multipart/form-data with an 'image' file field")
+	}
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	mr := multipart.NewReader(body, boundary)
+
+	var req inferReq
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return fiber.NewError(fiber.StatusBadRequest, "no 'image' file field in the form")
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if part.FormName() == "image" {
+			img, err := s.decodeUpload(part, s.preFor(req.Model))
+			if err != nil {
+				return err
+			}
+			img, clamped, err := s.normalizeClamped(inferReq{Image: img, Model: req.Model, Pad: req.Pad, PadFill: req.PadFill})
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			return s.inferImage(c, req, img, clamped)
+		}
+		v, err := io.ReadAll(io.LimitReader(part, uploadFieldMax+1))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if len(v) > uploadFieldMax {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("form field %q is longer than %d bytes", part.FormName(), uploadFieldMax))
+		}
+		if err := req.setFormField(part.FormName(), string(v)); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "form field "+err.Error())
+		}
+	}
+}
+
+// setFormField applies one upload form field or /score query parameter;
+// unknown names are ignored.
+func (req *inferReq) setFormField(name, v string) error {
+	var err error
+	switch name {
+	case "model":
+		req.Model = v
+	case "priority":
+		req.Priority = v
+	case "top_k":
+		req.TopK, err = strconv.Atoi(v)
+	case "min_confidence":
+		req.MinConf, err = strconv.ParseFloat(v, 64)
+	case "pad":
+		req.Pad, err = strconv.ParseBool(v)
+	case "pad_fill":
+		req.PadFill, err = strconv.ParseFloat(v, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// decodeUpload reads an image from r within the -max-upload-pixels budget
+// and returns it as [0,1] rows: luma for 1-channel models, interleaved RGB
+// otherwise (per pre), ready for normalizeInput's preset handling.
+func (s *Server) decodeUpload(r io.Reader, pre *preprocess) ([][]float64, error) {
+	var head bytes.Buffer
+	cfg, format, err := image.DecodeConfig(io.TeeReader(io.LimitReader(r, uploadHeaderMax), &head))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, fiber.NewError(fiber.StatusUnsupportedMediaType, "image must be PNG, JPEG or GIF")
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "image header: "+err.Error())
+	}
+	if px := cfg.Width * cfg.Height; px > s.maxUploadPixels {
+		return nil, fiber.NewError(fiber.StatusRequestEntityTooLarge,
+			fmt.Sprintf("%s image is %dx%d (%d pixels); -max-upload-pixels is %d", format, cfg.Width, cfg.Height, px, s.maxUploadPixels))
+	}
+	src, _, err := image.Decode(io.MultiReader(&head, r))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "image: "+err.Error())
+	}
+
+	b := src.Bounds()
+	rgb := pre.Channels == 3
+	out := make([][]float64, b.Dy())
+	for y := range out {
+		if rgb {
+			out[y] = make([]float64, 0, 3*b.Dx())
+		} else {
+			out[y] = make([]float64, 0, b.Dx())
+		}
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cr, cg, cb, _ := src.At(x, b.Min.Y+y).RGBA()
+			fr, fg, fb := float64(cr)/0xffff, float64(cg)/0xffff, float64(cb)/0xffff
+			if rgb {
+				out[y] = append(out[y], fr, fg, fb)
+			} else {
+				out[y] = append(out[y], 0.299*fr+0.587*fg+0.114*fb)
+			}
+		}
+	}
+	return out, nil
+}
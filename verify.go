@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// GPU/CPU agreement check (POST /verify)
+// ─────────────────────────────────────────────────────────────
+
+type verifyReq struct {
+	Batch    [][]float64   `json:"batch"`  // N × (w*h), as /infer-batch
+	Images   [][][]float64 `json:"images"` // N × h × w
+	Corpus   bool          `json:"corpus"` // run the -blast-corpus inputs (default when no samples are sent)
+	Model    string        `json:"model"`
+	Priority string        `json:"priority"` // high | normal (default) | low
+}
+
+type verifyResp struct {
+	N             int     `json:"n"`
+	MaxAbsDiff    float64 `json:"max_abs_diff"`  // over every output of every sample
+	MeanAbsDiff   float64 `json:"mean_abs_diff"` // over every output of every sample
+	WorstIndex    int     `json:"worst_index"`   // sample holding max_abs_diff
+	ArgmaxDiffers int     `json:"argmax_disagreements"`
+	DisagreeRate  float64 `json:"disagreement_rate"`
+	Disagreeing   []int   `json:"disagreeing"` // sample indices whose top class differs
+	Model         string  `json:"model"`
+	LatencyMs     float64 `json:"latency_ms"`
+}
+
+// handleVerify runs each input through the GPU and then the CPU path of a
+// GPU model and reports how far their raw outputs drift apart, to decide
+// whether a model can be trusted on this adapter before serving it there.
+// Both forwards of a sample run under one gpuMu hold, and the breaker is
+// bypassed: the point is to exercise the GPU, not to route around it.
+func (s *Server) handleVerify(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	var req verifyReq
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	m, err := s.lookupModel(req.Model)
+	if err != nil {
+		return err
+	}
+	if err := s.allowModel(c, m); err != nil {
+		return err
+	}
+	trackModel(c, m.ModelName)
+	if !m.GPU {
+		return fiber.NewError(fiber.StatusConflict, m.ModelName+" runs on CPU; there is no GPU output to compare")
+	}
+
+	var imgs [][][]float64
+	switch {
+	case len(req.Images) > 0 || len(req.Batch) > 0:
+		if total := max(len(req.Images), len(req.Batch)); total > s.maxBatch {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("batch of %d exceeds -max-batch %d", total, s.maxBatch))
+		}
+		for i := range max(len(req.Images), len(req.Batch)) {
+			// With m's own preprocessing, not the active model's.
+			in := inferReq{Model: m.ModelName}
+			if len(req.Images) > 0 {
+				in.Image = req.Images[i]
+			} else {
+				in.Input = req.Batch[i]
+			}
+			img, err := s.normalizeInput(in)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sample %d: %v", i, err))
+			}
+			imgs = append(imgs, img)
+		}
+	case s.corpus != nil:
+		imgs = s.corpus
+	case req.Corpus:
+		return fiber.NewError(fiber.StatusBadRequest, "corpus requested but the server has no -blast-corpus")
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "provide 'batch' or 'images', or start the server with -blast-corpus")
+	}
+	prio, err := parsePriority(req.Priority)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	slot, _, err := s.acquireN(c.UserContext(), prio, nil, len(imgs))
+	if err != nil {
+		return acquireError(err)
+	}
+	defer s.release(slot)
+	s.stats.observeSlot(slot)
+
+	start := time.Now()
+	resp := verifyResp{N: len(imgs), Disagreeing: []int{}, Model: m.ModelName}
+	var sum float64
+	var count int
+	for i, img := range imgs {
+		if ctx := c.UserContext(); ctx.Err() != nil {
+			return forwardError(ctxErr(ctx))
+		}
+		gpuOut, cpuOut, err := s.forwardBoth(m, img)
+		if err != nil {
+			return forwardError(fmt.Errorf("sample %d: %w", i, err))
+		}
+		for k := range gpuOut {
+			d := math.Abs(gpuOut[k] - cpuOut[k])
+			sum += d
+			if d > resp.MaxAbsDiff {
+				resp.MaxAbsDiff, resp.WorstIndex = d, i
+			}
+		}
+		count += len(gpuOut)
+		if argmax64(gpuOut) != argmax64(cpuOut) {
+			resp.ArgmaxDiffers++
+			resp.Disagreeing = append(resp.Disagreeing, i)
+		}
+	}
+	if count > 0 {
+		resp.MeanAbsDiff = sum / float64(count)
+	}
+	resp.DisagreeRate = float64(resp.ArgmaxDiffers) / float64(len(imgs))
+	resp.LatencyMs = durMs(time.Since(start))
+	return c.JSON(resp)
+}
+
+// forwardBoth runs img on m's GPU path, then on its CPU path, and returns
+// both outputs.
+func (s *Server) forwardBoth(m *Model, img [][]float64) ([]float64, []float64, error) {
+	s.gpuMu.Lock()
+	defer s.gpuMu.Unlock()
+	if !m.NN.WebGPUNative {
+		return nil, nil, fmt.Errorf("%s was retired by a reload", m.ModelName)
+	}
+	if err := gpuForward(m.NN, img); err != nil {
+		return nil, nil, err
+	}
+	gpuOut := m.NN.ExtractOutput()
+	cpuForward(m.NN, img)
+	cpuOut := m.NN.ExtractOutput()
+	if len(gpuOut) == 0 || len(gpuOut) != len(cpuOut) {
+		return nil, nil, errNoOutput
+	}
+	return gpuOut, cpuOut, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openfluke/paragon/v3"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Model format version check
+// ─────────────────────────────────────────────────────────────
+
+const paragonModule = "github.com/openfluke/paragon/v3"
+
+// strictVersion is set by -strict-version before any model is loaded:
+// a version mismatch then fails the load instead of logging a warning.
+var strictVersion bool
+
+// paragonVersion is the paragon release this binary was built against,
+// from the module build info. paragon.Version lags the module tag, so it
+// is only the fallback (e.g. for builds without module info).
+var paragonVersion = sync.OnceValue(func() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, d := range bi.Deps {
+			if d.Path == paragonModule {
+				if d.Replace != nil && d.Replace.Version != "" {
+					return strings.TrimPrefix(d.Replace.Version, "v")
+				}
+				if d.Version != "" && d.Version != "(devel)" {
+					return strings.TrimPrefix(d.Version, "v")
+				}
+			}
+		}
+	}
+	return paragon.Version
+})
+
+// checkModelVersion compares the model JSON's top-level "version" marker
+// (the paragon release that wrote it) with paragonVersion. A different
+// major version, or a newer minor one, may use format features this build
+// silently ignores. Models without a marker are accepted as they are.
+func checkModelVersion(data []byte) error {
+	var head struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil || head.Version == "" {
+		return nil // the parse proper reports malformed JSON
+	}
+	have := paragonVersion()
+	if compatibleVersion(head.Version, have) {
+		return nil
+	}
+	msg := fmt.Sprintf("model was written by paragon %s, server is built with %s", head.Version, have)
+	if strictVersion {
+		return fmt.Errorf("%s (-strict-version)", msg)
+	}
+	log.Printf("WARN: %s; it may load incompletely.", msg)
+	return nil
+}
+
+// compatibleVersion reports whether a model written by paragon `model`
+// can be read by paragon `built`: same major, and a minor no newer.
+func compatibleVersion(model, built string) bool {
+	mMaj, mMin, ok1 := majorMinor(model)
+	bMaj, bMin, ok2 := majorMinor(built)
+	if !ok1 || !ok2 {
+		return model == built
+	}
+	return mMaj == bMaj && mMin <= bMin
+}
+
+func majorMinor(v string) (int, int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	maj, err1 := strconv.Atoi(parts[0])
+	mnr, err2 := strconv.Atoi(parts[1])
+	return maj, mnr, err1 == nil && err2 == nil
+}
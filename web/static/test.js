@@ -2,8 +2,15 @@
   const el = (id) => document.getElementById(id);
   const status = (t) => (el("status").textContent = t);
 
+  // Responses arrive as {"ok":true,"data":…} when the server runs with
+  // -envelope; unwrap them so the rest of the page sees the raw shape.
+  const readJSON = (r) =>
+    r.json().then((js) => (js && js.ok === true && "data" in js ? js.data : js));
+  // The page reads snake_case keys whatever -json-case the server runs with.
+  const JSON_HDRS = { "content-type": "application/json", "x-json-case": "snake" };
+
   // Config
-  const cfg = await fetch("/config").then((r) => r.json());
+  const cfg = await fetch("/config", { headers: { "x-json-case": "snake" } }).then(readJSON);
   el("dims").textContent = `${cfg.input[1]}×${cfg.input[0]} (h×w)`;
   el("gpu").textContent = cfg.gpu ? "GPU (WebGPU) ✓" : "CPU fallback";
   el("model").textContent = `${cfg.model}`;
@@ -11,13 +18,16 @@
     H = cfg.input[1],
     NPIX = W * H;
 
-  // Health poll
-  setInterval(async () => {
-    try {
-      const h = await fetch("/health").then((r) => r.json());
-      el("inflight").textContent = h.inflight;
-    } catch {}
-  }, 1000);
+  // Live gauges (SSE); EventSource reconnects on its own after a drop
+  const live = new EventSource("/stats/stream?json_case=snake");
+  live.addEventListener("stats", (ev) => {
+    const st = JSON.parse(ev.data);
+    el("inflight").textContent = st.inflight;
+    el("queued").textContent = st.queued;
+    el("rps").textContent = num(st.throughput_rps, 1);
+    el("gpuBusy").textContent = `${num(st.gpu_busy * 100, 0)}%`;
+  });
+  window.addEventListener("beforeunload", () => live.close());
 
   // Session + run capture
   let sessionActive = false;
@@ -47,13 +57,20 @@
         id: `sess_${new Date().toISOString().replace(/[:.]/g, "-")}`,
         started_at: new Date().toISOString(),
         model: cfg.model,
-        modelPath: cfg.modelPath,
+        modelPath: cfg.model_path,
         gpu: cfg.gpu,
         input: cfg.input,
+        inputs: [], // one per run; results point here via input_ref for replay
         results: [],
       };
     }
   }
+  function rememberInput(x) {
+    if (!sessionActive) return null;
+    ensureSession();
+    session.inputs.push(x);
+    return session.inputs.length - 1;
+  }
 
   function startSession() {
     if (sessionActive) return;
@@ -154,9 +171,9 @@
     }
     const res = await fetch("/save-session", {
       method: "POST",
-      headers: { "content-type": "application/json" },
+      headers: JSON_HDRS,
       body: JSON.stringify(session),
-    }).then((r) => r.json());
+    }).then(readJSON);
     el("log").textContent =
       `saved=${res.saved} path=${res.path} bytes=${res.bytes}\n` +
       el("log").textContent;
@@ -168,6 +185,33 @@
     else for (let i = 0; i < NPIX; i++) x[i] = Math.random();
     return x;
   }
+  // Draw input_used (h×w, channels interleaved per row) so you can see
+  // exactly what the model got. Values are rescaled to the matrix's own
+  // min..max since standardized inputs leave [0,1].
+  function drawInputUsed(img) {
+    const ch = (cfg.preprocess && cfg.preprocess.channels) || 1;
+    const h = img.length,
+      w = img[0].length / ch;
+    const flat = img.flat();
+    const lo = Math.min(...flat),
+      span = Math.max(...flat) - lo || 1;
+    const px = new ImageData(w, h);
+    for (let y = 0; y < h; y++)
+      for (let x = 0; x < w; x++)
+        for (let c = 0; c < 3; c++) {
+          const v = img[y][x * ch + (ch === 3 ? c : 0)];
+          px.data[(y * w + x) * 4 + c] = ((v - lo) / span) * 255;
+        }
+    for (let i = 3; i < px.data.length; i += 4) px.data[i] = 255;
+    const off = document.createElement("canvas");
+    off.width = w;
+    off.height = h;
+    off.getContext("2d").putImageData(px, 0, 0);
+    const ctx = el("inputUsed").getContext("2d");
+    ctx.imageSmoothingEnabled = false;
+    ctx.clearRect(0, 0, ctx.canvas.width, ctx.canvas.height);
+    ctx.drawImage(off, 0, 0, ctx.canvas.width, ctx.canvas.height);
+  }
   function percentiles(arr) {
     const a = arr.slice().sort((x, y) => x - y);
     const pick = (p) =>
@@ -262,7 +306,7 @@
     if (last && Array.isArray(last.probs)) renderProbsPanel(last.probs);
   });
 
-  function recordResult(mode, req_id, res, clientMs) {
+  function recordResult(mode, req_id, res, clientMs, inputRef) {
     const item = {
       req_id,
       mode,
@@ -275,6 +319,7 @@
       top_score: res.top_score,
       probs: res.probs,
     };
+    if (inputRef != null) item.input_ref = inputRef;
     collected.push(item);
     if (sessionActive) {
       ensureSession();
@@ -285,8 +330,10 @@
 
   async function clientMode(N, parallel, kind) {
     const x = makeInput(kind);
+    const ref = rememberInput(x);
     const body = JSON.stringify({ input: x });
-    const headers = { "content-type": "application/json" };
+    // Only the first request echoes its input; they all send the same one.
+    const echoBody = JSON.stringify({ input: x, echo_input: true });
     collected = [];
     const startAll = performance.now();
     const lat = [];
@@ -294,11 +341,16 @@
 
     async function one(ix) {
       const t0 = performance.now();
-      const r = await fetch("/infer", { method: "POST", headers, body });
-      const js = await r.json();
+      const r = await fetch("/infer", {
+        method: "POST",
+        headers: JSON_HDRS,
+        body: ix === 0 ? echoBody : body,
+      });
+      const js = await readJSON(r);
+      if (js.input_used) drawInputUsed(js.input_used);
       const dt = performance.now() - t0;
       lat.push(dt);
-      recordResult("client", ix, js, dt);
+      recordResult("client", ix, js, dt, ref);
       appendRow("client", ix, js, dt);
       return js;
     }
@@ -328,16 +380,17 @@
 
   async function serverMode(N, kind) {
     const x = makeInput(kind);
+    const ref = rememberInput(x);
     collected = [];
     const res = await fetch("/blast", {
       method: "POST",
-      headers: { "content-type": "application/json" },
+      headers: JSON_HDRS,
       body: JSON.stringify({ n: N, input: x }),
-    }).then((r) => r.json());
+    }).then(readJSON);
 
     const lat = res.results.map((r) => r.latency_ms);
     res.results.forEach((r, i) => {
-      recordResult("server", i, r, null);
+      recordResult("server", i, r, null, ref);
       appendRow("server", i, r, null);
     });
     finalizeRun(lat, res.total_ms);
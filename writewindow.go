@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Size-aware write deadlines (-write-timeout, -min-write-rate)
+// ─────────────────────────────────────────────────────────────
+
+// writePacedMin is the body size above which a response is paced: smaller
+// ones fit in the socket buffers and -write-timeout covers them.
+const writePacedMin = 64 << 10
+
+// writeWindow gives large buffered responses a write deadline that grows
+// with the bytes sent: a client must take the first of them within base
+// and then keep reading at minRate bytes/s on average. fasthttp sets one
+// deadline for the whole response, which a slow reader can hold open for
+// all of it and a big /infer-batch or /blast body on a slow link can miss.
+// Streamed responses set their own deadlines per line.
+func writeWindow(base time.Duration, minRate int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil || minRate <= 0 {
+			return err
+		}
+		resp := c.Response()
+		if resp.IsBodyStream() || len(resp.Body()) <= writePacedMin {
+			return nil
+		}
+		body := bytes.Clone(resp.Body()) // SetBodyStream releases the buffer
+		resp.SetBodyStream(&pacedBody{r: bytes.NewReader(body), conn: c.Context().Conn(), base: base, rate: minRate}, len(body))
+		return nil
+	}
+}
+
+// pacedBody moves the connection's write deadline forward before handing
+// out each piece of the body, so the piece fasthttp writes next is due by
+// base + (bytes so far) / rate after the first read.
+type pacedBody struct {
+	r     *bytes.Reader
+	conn  net.Conn
+	base  time.Duration
+	rate  int
+	start time.Time
+	sent  int64
+}
+
+func (p *pacedBody) Read(b []byte) (int, error) {
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	n, err := p.r.Read(b)
+	p.sent += int64(n)
+	due := p.base + time.Duration(p.sent)*time.Second/time.Duration(p.rate)
+	p.conn.SetWriteDeadline(p.start.Add(due))
+	return n, err
+}